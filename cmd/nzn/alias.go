@@ -1,7 +1,7 @@
 //
 // nazuna/cmd/nzn :: alias.go
 //
-//   Copyright (c) 2013-2020 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -31,6 +31,11 @@ func init() {
 
 			  You can refer environment variables in <dst>. Supported formats are ${var}
 			  and $var.
+
+			  <src> is relative to the working copy. <dst> is relative to the current
+			  directory, or to the working copy when the current directory is outside of
+			  it. <dst> must be under the working copy, or under the directories in
+			  "roots" of .nzn/config.json.
 		`)),
 		Flags:  flags,
 		Action: alias,
@@ -60,7 +65,7 @@ func alias(ctx *cli.Context) error {
 		if err != nil {
 			return err
 		}
-		dst, err := wc.Rel('.', ctx.Args[1])
+		dst, err := relPath(wc, ctx.Args[1])
 		if err != nil {
 			return err
 		}
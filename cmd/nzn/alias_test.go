@@ -1,7 +1,7 @@
 //
 // nazuna/cmd/nzn :: alias_test.go
 //
-//   Copyright (c) 2013-2022 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -145,6 +145,60 @@ func TestAlias(t *testing.T) {
 	}
 }
 
+func TestAliasTarget(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git", "--target", "$wc", "$public/repo"},
+		},
+		{
+			cmd: []string{"cd", "$public/repo"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.gitconfig"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "b/1"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "add", "."},
+		},
+		{
+			cmd: []string{"nzn", "alias", "-l", "b/1", ".gitconfig", ".config/git/config"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "b/1"},
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				link .config/git/config --> a:.gitconfig
+				1 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"ls", "$wc/.config/git"},
+			out: cli.Dedent(`
+				config
+			`),
+		},
+		{
+			cmd: []string{"ls", "."},
+			out: cli.Dedent(`
+				.nzn/
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestAliasError(t *testing.T) {
 	s := script{
 		{
@@ -191,6 +245,11 @@ func TestAliasError(t *testing.T) {
 				  You can refer environment variables in <dst>. Supported formats are ${var}
 				  and $var.
 
+				  <src> is relative to the working copy. <dst> is relative to the current
+				  directory, or to the working copy when the current directory is outside of
+				  it. <dst> must be under the working copy, or under the directories in
+				  "roots" of .nzn/config.json.
+
 				options:
 
 				  -l, --layer <layer>    layer name
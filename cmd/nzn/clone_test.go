@@ -1,7 +1,7 @@
 //
 // nazuna/cmd/nzn :: clone_test.go
 //
-//   Copyright (c) 2013-2024 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -47,6 +47,7 @@ func TestClone(t *testing.T) {
 		{
 			cmd: []string{"ls", "wc/.nzn"},
 			out: cli.Dedent(`
+				config.json
 				r/
 			`),
 		},
@@ -82,7 +83,7 @@ func TestCloneError(t *testing.T) {
 			cmd: []string{"nzn", "clone", "$public/repo", "wc"},
 			out: cli.Dedent(`
 				nzn clone: --vcs flag is required (re)
-				usage: nzn clone --vcs <type> <repository> [<path>]
+				usage: nzn clone --vcs <type> [--target <dir>] <repository> [<path>]
 
 				create a copy of an existing repository
 
@@ -91,9 +92,13 @@ func TestCloneError(t *testing.T) {
 
 				  If <path> is not specified, the current working directory is used.
 
+				  The working copy is <path> by default. If --target flag is specified, <dir>
+				  is used as the working copy instead, and it is recorded in .nzn/config.json.
+
 				options:
 
-				  --vcs <type>    vcs type
+				  --target <dir>    working copy directory
+				  --vcs <type>      vcs type
 
 				[2]
 			`),
@@ -105,6 +110,13 @@ func TestCloneError(t *testing.T) {
 				[1]
 			`),
 		},
+		{
+			cmd: []string{"nzn", "clone", "--vcs", "git", "--target", "none", "$public/repo", "wc"},
+			out: cli.Dedent(`
+				nzn: target 'none' is not a directory!
+				[1]
+			`),
+		},
 		{
 			cmd: []string{"nzn", "init", "--vcs", "git", "wc"},
 		},
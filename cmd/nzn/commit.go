@@ -0,0 +1,63 @@
+//
+// nazuna/cmd/nzn :: commit.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/hattya/go.cli"
+	"github.com/hattya/nazuna"
+)
+
+func init() {
+	flags := cli.NewFlagSet()
+	flags.String("l, layer", "", "layer name")
+	flags.String("m, message", "", "commit message")
+
+	app.Add(&cli.Command{
+		Name:  []string{"commit"},
+		Usage: "[-l <layer>] -m <message> [<path>...]",
+		Desc: strings.TrimSpace(cli.Dedent(`
+			commit changes of the repository
+
+			  commit records changes of the tracked files in the repository. If <path>
+			  is specified, only changes of <path> are recorded.
+
+			  If --layer flag is specified, changes of the layer are recorded, and <path>
+			  is relative to the layer.
+		`)),
+		Flags:  flags,
+		Action: commit,
+		Data:   true,
+	})
+}
+
+func commit(ctx *cli.Context) error {
+	repo := ctx.Data.(*nazuna.Repository)
+	if ctx.String("message") == "" {
+		return cli.FlagError("--message flag is required")
+	}
+	paths := ctx.Args
+	if ctx.String("layer") != "" {
+		l, err := repo.LayerOf(ctx.String("layer"))
+		if err != nil {
+			return err
+		}
+		if len(ctx.Args) == 0 {
+			paths = []string{l.Path()}
+		} else {
+			paths = make([]string, len(ctx.Args))
+			for i, p := range ctx.Args {
+				paths[i] = filepath.ToSlash(filepath.Join(l.Path(), p))
+			}
+		}
+	}
+	return repo.Commit(ctx.String("message"), paths...)
+}
@@ -0,0 +1,127 @@
+//
+// nazuna/cmd/nzn :: commit_test.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package main
+
+import (
+	"testing"
+
+	"github.com/hattya/go.cli"
+)
+
+func TestCommit(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "b/1"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.vimrc"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/b/1/.vimrc"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "add", "."},
+		},
+		{
+			cmd: []string{"nzn", "commit", "-l", "a", "-m", "a"},
+		},
+		{
+			cmd: []string{"nzn", "status"},
+			out: cli.Dedent(`
+				A b/1:.vimrc
+				A nazuna.json
+			`),
+		},
+		{
+			cmd: []string{"nzn", "commit", "-l", "b", "-m", "b", "1/.vimrc"},
+		},
+		{
+			cmd: []string{"nzn", "commit", "-m", "nazuna"},
+		},
+		{
+			cmd: []string{"nzn", "status"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "log", "--format=%s"},
+			out: cli.Dedent(`
+				nazuna
+				b
+				a
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCommitError(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"nzn", "commit", "-m", "."},
+			out: cli.Dedent(`
+				nzn: no repository found in '.+' \(\.nzn not found\)! (re)
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "commit"},
+			out: cli.Dedent(`
+				nzn commit: --message flag is required
+				usage: nzn commit [-l <layer>] -m <message> [<path>...]
+
+				commit changes of the repository
+
+				  commit records changes of the tracked files in the repository. If <path>
+				  is specified, only changes of <path> are recorded.
+
+				  If --layer flag is specified, changes of the layer are recorded, and <path>
+				  is relative to the layer.
+
+				options:
+
+				  -l, --layer <layer>        layer name
+				  -m, --message <message>    commit message
+
+				[2]
+			`),
+		},
+		{
+			cmd: []string{"nzn", "commit", "-l", "a", "-m", "."},
+			out: cli.Dedent(`
+				nzn: layer 'a' does not exist!
+				[1]
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,138 @@
+//
+// nazuna/cmd/nzn :: config.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hattya/nazuna"
+)
+
+type config struct {
+	Remotes   []*remoteConfig  `json:"remotes,omitempty"`
+	HTTP      *httpConfig      `json:"http,omitempty"`
+	Mercurial *mercurialConfig `json:"mercurial,omitempty"`
+}
+
+type remoteConfig struct {
+	Prefix string `json:"prefix"`
+	Expr   string `json:"expr"`
+	VCS    string `json:"vcs"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+type httpConfig struct {
+	Timeout string            `json:"timeout,omitempty"`
+	CA      string            `json:"ca,omitempty"`
+	Tokens  map[string]string `json:"tokens,omitempty"`
+	Retries int               `json:"retries,omitempty"`
+}
+
+type mercurialConfig struct {
+	CmdServer bool `json:"cmdserver,omitempty"`
+}
+
+func (hc *httpConfig) client(dir string) (*nazuna.HTTPClient, error) {
+	c := &nazuna.HTTPClient{
+		Tokens:  hc.Tokens,
+		Retries: hc.Retries,
+	}
+	if hc.Timeout == "" && hc.CA == "" {
+		return c, nil
+	}
+	c.Client = new(http.Client)
+	if hc.Timeout != "" {
+		d, err := time.ParseDuration(hc.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout '%v'", hc.Timeout)
+		}
+		c.Client.Timeout = d
+	}
+	if hc.CA != "" {
+		// relative to the configuration file
+		ca := hc.CA
+		if !filepath.IsAbs(ca) {
+			ca = filepath.Join(dir, ca)
+		}
+		data, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read '%v'", hc.CA)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in '%v'", hc.CA)
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = &tls.Config{RootCAs: pool}
+		c.Client.Transport = t
+	}
+	return c, nil
+}
+
+func configPath() string {
+	if p := os.Getenv("NAZUNA_CONFIG"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "nazuna", "config.json")
+}
+
+func loadConfig() (*nazuna.HTTPClient, error) {
+	nazuna.CommandServer(false)
+
+	path := configPath()
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("cannot read '%v'", path)
+	}
+	var c config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	for _, r := range c.Remotes {
+		err := nazuna.RegisterRemote(&nazuna.RemoteHandler{
+			Prefix: r.Prefix,
+			Expr:   r.Expr,
+			VCS:    r.VCS,
+			Scheme: r.Scheme,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", path, err)
+		}
+	}
+	var hc *nazuna.HTTPClient
+	if c.HTTP != nil {
+		if hc, err = c.HTTP.client(filepath.Dir(path)); err != nil {
+			return nil, fmt.Errorf("%v: %v", path, err)
+		}
+	}
+	if c.Mercurial != nil {
+		nazuna.CommandServer(c.Mercurial.CmdServer)
+	}
+	return hc, nil
+}
@@ -0,0 +1,169 @@
+//
+// nazuna/cmd/nzn :: config_test.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hattya/go.cli"
+)
+
+func TestConfig(t *testing.T) {
+	sh, err := newShell(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := `{
+  "remotes": [
+    {
+      "prefix": "git.example.com/",
+      "expr": "^(?P<root>git\\.example\\.com/[^/]+/[^/]+)(?P<path>.*)$",
+      "vcs": "hg"
+    }
+  ]
+}
+`
+	if err := os.WriteFile(filepath.Join(sh.dir, "config.json"), []byte(data), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"export", "NAZUNA_CONFIG=$tempdir/config.json"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-a", "git.example.com/hattya/repo", ".vim/bundle/"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "--list"},
+			out: cli.Dedent(`
+				.vim/bundle/repo --> git.example.com/hattya/repo
+				    layer:    a
+				    vcs:      hg
+				    clone:    .nzn/sub/git.example.com/hattya/repo (not cloned)
+			`),
+		},
+	}
+	if err := sh.run(s); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConfigError(t *testing.T) {
+	sh, err := newShell(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(sh.dir, "broken.json"), []byte("{"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	data := `{"remotes": [{"prefix": "example.com/", "expr": "^example\\.com/", "vcs": "git"}]}`
+	if err := os.WriteFile(filepath.Join(sh.dir, "config.json"), []byte(data), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	for n, data := range map[string]string{
+		"timeout.json": `{"http": {"timeout": "1"}}`,
+		"ca.json":      `{"http": {"ca": "_.pem"}}`,
+		"pem.json":     `{"http": {"ca": "broken.json"}}`,
+	} {
+		if err := os.WriteFile(filepath.Join(sh.dir, n), []byte(data), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"export", "NAZUNA_CONFIG=$tempdir/broken.json"},
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				nzn: .+` + quote("/broken.json") + `: unexpected end of JSON input (re)
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"export", "NAZUNA_CONFIG=$tempdir/config.json"},
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				nzn: .+` + quote("/config.json") + `: remote 'example.com/': group 'root' is not defined (re)
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"export", "NAZUNA_CONFIG=$tempdir/timeout.json"},
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				nzn: .+` + quote("/timeout.json") + `: invalid timeout '1' (re)
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"export", "NAZUNA_CONFIG=$tempdir/ca.json"},
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				nzn: .+` + quote("/ca.json") + `: cannot read '_.pem' (re)
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"export", "NAZUNA_CONFIG=$tempdir/pem.json"},
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				nzn: .+` + quote("/pem.json") + `: no certificates found in 'broken.json' (re)
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"export", "NAZUNA_CONFIG=$tempdir/_.json"},
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				0 updated, 0 removed, 0 failed
+			`),
+		},
+	}
+	if err := sh.run(s); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,7 +1,7 @@
 //
 // nazuna/cmd/nzn :: help_test.go
 //
-//   Copyright (c) 2013-2022 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -28,11 +28,16 @@ var (
 
 		  alias      create an alias for the specified path
 		  clone      create a copy of an existing repository
+		  commit     commit changes of the repository
 		  help       show help for a specified command
 		  init       create a new repository in the specified directory
 		  layer      manage repository layers
 		  link       create a link for the specified path
+		  push       push changes of the repository to its remote
+		  scripts    manage setup scripts
+		  status     show changed files in the repository
 		  subrepo    manage subrepositories
+		  sync       synchronize the working copy with the remote repository
 		  update     update working copy
 		  vcs        run the vcs command inside the repository
 		  version    show version information
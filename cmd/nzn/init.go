@@ -1,7 +1,7 @@
 //
 // nazuna/cmd/nzn :: init.go
 //
-//   Copyright (c) 2013-2024 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -10,6 +10,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -22,10 +23,12 @@ func init() {
 	flags := cli.NewFlagSet()
 	flags.String("vcs", "", "vcs type")
 	flags.MetaVar("vcs", " <type>")
+	flags.String("target", "", "working copy directory")
+	flags.MetaVar("target", " <dir>")
 
 	app.Add(&cli.Command{
 		Name:  []string{"init"},
-		Usage: "--vcs <type> [<path>]",
+		Usage: "--vcs <type> [--target <dir>] [<path>]",
 		Desc: strings.TrimSpace(cli.Dedent(`
 			create a new repository in the specified directory
 
@@ -33,6 +36,9 @@ func init() {
 			  created.
 
 			  If <path> is not specified, the current working directory is used.
+
+			  The working copy is <path> by default. If --target flag is specified, <dir>
+			  is used as the working copy instead, and it is recorded in .nzn/config.json.
 		`)),
 		Flags:  flags,
 		Action: init_,
@@ -52,11 +58,24 @@ func init_(ctx *cli.Context) error {
 	if ctx.String("vcs") == "" {
 		return cli.FlagError("--vcs flag is required")
 	}
+	var target string
+	if ctx.String("target") != "" {
+		var err error
+		if target, err = filepath.Abs(ctx.String("target")); err != nil {
+			return err
+		}
+		if !nazuna.IsDir(target) {
+			return fmt.Errorf("target '%v' is not a directory!", ctx.String("target"))
+		}
+	}
 	ui := newUI()
 	vcs, err := nazuna.FindVCS(ui, ctx.String("vcs"), "")
 	if err != nil {
 		return err
 	}
+	if c, ok := vcs.(io.Closer); ok {
+		defer c.Close()
+	}
 	if err := os.MkdirAll(nzndir, 0o777); err != nil {
 		return err
 	}
@@ -68,6 +87,11 @@ func init_(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	repo.Config.VCS = ctx.String("vcs")
+	repo.Config.Target = target
+	if err := repo.FlushConfig(); err != nil {
+		return err
+	}
 	if err := repo.Flush(); err != nil {
 		return err
 	}
@@ -1,7 +1,7 @@
 //
 // nazuna/cmd/nzn :: init_test.go
 //
-//   Copyright (c) 2013-2024 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -25,9 +25,18 @@ func TestInit(t *testing.T) {
 		{
 			cmd: []string{"ls", "$wc/.nzn"},
 			out: cli.Dedent(`
+				config.json
 				r/
 			`),
 		},
+		{
+			cmd: []string{"cat", "$wc/.nzn/config.json"},
+			out: cli.Dedent(`
+				{
+				  "vcs": "git"
+				}
+			`),
+		},
 		{
 			cmd: []string{"ls", "$wc/.nzn/r"},
 			out: cli.Dedent(`
@@ -47,6 +56,120 @@ func TestInit(t *testing.T) {
 	}
 }
 
+func TestInitNone(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "none"},
+		},
+		{
+			cmd: []string{"ls", ".nzn/r"},
+			out: cli.Dedent(`
+				.none/
+				nazuna.json
+			`),
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.vimrc"},
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				link .vimrc --> a
+				1 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"nzn", "status"},
+		},
+		{
+			cmd: []string{"nzn", "commit", "-m", "."},
+			out: cli.Dedent(`
+				nzn: VCS.Commit not supported
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"nzn", "sync"},
+			out: cli.Dedent(`
+				0 updated, 0 removed, 0 failed, 0 subrepos
+			`),
+		},
+		{
+			cmd: []string{"nzn", "vcs", "status"},
+			out: cli.Dedent(`
+				nzn: VCS.Exec not implemented
+				[1]
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInitTarget(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git", "--target", "$wc", "$public/repo"},
+		},
+		{
+			cmd: []string{"ls", "$wc"},
+		},
+		{
+			cmd: []string{"cat", "$public/repo/.nzn/config.json"},
+			out: cli.Dedent(`
+				{
+				  "vcs": "git",
+				  "target": ".+"
+				}
+			`),
+		},
+		{
+			cmd: []string{"cd", "$public/repo"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.vimrc"},
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				link .vimrc --> a
+				1 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"ls", "$wc"},
+			out: cli.Dedent(`
+				.vimrc
+			`),
+		},
+		{
+			cmd: []string{"ls", "."},
+			out: cli.Dedent(`
+				.nzn/
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestInitError(t *testing.T) {
 	s := script{
 		{
@@ -56,7 +179,7 @@ func TestInitError(t *testing.T) {
 			cmd: []string{"nzn", "init", "$wc"},
 			out: cli.Dedent(`
 				nzn init: --vcs flag is required
-				usage: nzn init --vcs <type> [<path>]
+				usage: nzn init --vcs <type> [--target <dir>] [<path>]
 
 				create a new repository in the specified directory
 
@@ -65,9 +188,13 @@ func TestInitError(t *testing.T) {
 
 				  If <path> is not specified, the current working directory is used.
 
+				  The working copy is <path> by default. If --target flag is specified, <dir>
+				  is used as the working copy instead, and it is recorded in .nzn/config.json.
+
 				options:
 
-				  --vcs <type>    vcs type
+				  --target <dir>    working copy directory
+				  --vcs <type>      vcs type
 
 				[2]
 			`),
@@ -79,6 +206,16 @@ func TestInitError(t *testing.T) {
 				[1]
 			`),
 		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git", "--target", "$tempdir/none", "$wc"},
+			out: cli.Dedent(`
+				nzn: target '.+' is not a directory! (re)
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"ls", "$wc"},
+		},
 		{
 			cmd: []string{"mkdir", "$wc/.nzn/r"},
 		},
@@ -29,6 +29,8 @@ func init() {
 
 			  link is used to create a link of <src> to <dst>, and will be managed by
 			  update. If <src> is not found on update, it will be ignored without error.
+			  <dst> is relative to the current directory, or to the working copy when the
+			  current directory is outside of it.
 
 			  The value of --path flag is a list of directories like PATH or GOPATH
 			  environment variables, and it is used to search <src>.
@@ -60,7 +62,7 @@ func link(ctx *cli.Context) error {
 		if err != nil {
 			return err
 		}
-		dst, err := wc.Rel('.', ctx.Args[1])
+		dst, err := relPath(wc, ctx.Args[1])
 		if err != nil {
 			return err
 		}
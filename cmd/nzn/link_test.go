@@ -1,7 +1,7 @@
 //
 // nazuna/cmd/nzn :: link_test.go
 //
-//   Copyright (c) 2013-2022 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -13,9 +13,13 @@ import (
 	"testing"
 
 	"github.com/hattya/go.cli"
+	"github.com/hattya/nazuna"
 )
 
 func TestLink(t *testing.T) {
+	// run in a subdirectory of the working copy
+	defer nazuna.Discover(nazuna.Discover(true))
+
 	sep := string(os.PathListSeparator)
 	s := script{
 		{
@@ -43,7 +47,16 @@ func TestLink(t *testing.T) {
 			cmd: []string{"nzn", "vcs", "add", "."},
 		},
 		{
-			cmd: []string{"nzn", "link", "-l", "a", "$public/go/misc/vim", ".vim/bundle/golang"},
+			cmd: []string{"mkdir", ".vim"},
+		},
+		{
+			cmd: []string{"cd", "$wc/.vim"},
+		},
+		{
+			cmd: []string{"nzn", "link", "-l", "a", "$public/go/misc/vim", "bundle/golang"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
 		},
 		{
 			cmd: []string{"export", "GOPATH="},
@@ -93,6 +106,51 @@ func TestLink(t *testing.T) {
 	}
 }
 
+func TestLinkTarget(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"mkdir", "$public/go/misc/vim"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git", "--target", "$wc", "$public/repo"},
+		},
+		{
+			cmd: []string{"cd", "$public/repo"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"nzn", "link", "-l", "a", "$public/go/misc/vim", ".vim/bundle/golang"},
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				link .vim/bundle/golang/ --> .+` + quote("/go/misc/vim/") + ` (re)
+				1 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"ls", "$wc/.vim/bundle"},
+			out: cli.Dedent(`
+				golang
+			`),
+		},
+		{
+			cmd: []string{"ls", "."},
+			out: cli.Dedent(`
+				.nzn/
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestLinkError(t *testing.T) {
 	s := script{
 		{
@@ -134,6 +192,8 @@ func TestLinkError(t *testing.T) {
 
 				  link is used to create a link of <src> to <dst>, and will be managed by
 				  update. If <src> is not found on update, it will be ignored without error.
+				  <dst> is relative to the current directory, or to the working copy when the
+				  current directory is outside of it.
 
 				  The value of --path flag is a list of directories like PATH or GOPATH
 				  environment variables, and it is used to search <src>.
@@ -1,7 +1,7 @@
 //
 // nazuna/cmd/nzn :: nzn.go
 //
-//   Copyright (c) 2013-2020 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -17,12 +17,18 @@ import (
 	"github.com/hattya/nazuna"
 )
 
-var app = cli.NewCLI()
+var (
+	app = cli.NewCLI()
+	// repository opened by prepare
+	current *nazuna.Repository
+)
 
 func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
-	if err := app.Run(os.Args[1:]); err != nil {
+	err := app.Run(os.Args[1:])
+	closeRepo()
+	if err != nil {
 		switch err := err.(type) {
 		case cli.FlagError:
 			os.Exit(2)
@@ -42,6 +48,10 @@ func init() {
 }
 
 func prepare(ctx *cli.Context, cmd *cli.Command) error {
+	hc, err := loadConfig()
+	if err != nil {
+		return err
+	}
 	if v, ok := cmd.Data.(bool); ok && v {
 		wd, err := os.Getwd()
 		if err != nil {
@@ -51,11 +61,20 @@ func prepare(ctx *cli.Context, cmd *cli.Command) error {
 		if err != nil {
 			return err
 		}
+		repo.HTTPClient = hc
 		ctx.Data = repo
+		current = repo
 	}
 	return nil
 }
 
+func closeRepo() {
+	if current != nil {
+		current.Close()
+		current = nil
+	}
+}
+
 func errorHandler(ctx *cli.Context, err error) error {
 	switch err.(type) {
 	case cli.FlagError:
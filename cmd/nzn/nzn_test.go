@@ -1,7 +1,7 @@
 //
 // nazuna/cmd/nzn :: nzn_test.go
 //
-//   Copyright (c) 2013-2025 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -256,7 +256,9 @@ func (sh *shell) nzn(args ...string) (string, int) {
 	app.Stderr = &b
 
 	rc := 0
-	if err := app.Run(args); err != nil {
+	err := app.Run(args)
+	closeRepo()
+	if err != nil {
 		switch err := err.(type) {
 		case cli.FlagError:
 			rc = 2
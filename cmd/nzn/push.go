@@ -0,0 +1,35 @@
+//
+// nazuna/cmd/nzn :: push.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package main
+
+import (
+	"strings"
+
+	"github.com/hattya/go.cli"
+	"github.com/hattya/nazuna"
+)
+
+func init() {
+	app.Add(&cli.Command{
+		Name: []string{"push"},
+		Desc: strings.TrimSpace(cli.Dedent(`
+			push changes of the repository to its remote
+		`)),
+		Action: push,
+		Data:   true,
+	})
+}
+
+func push(ctx *cli.Context) error {
+	if len(ctx.Args) != 0 {
+		return cli.ErrArgs
+	}
+	repo := ctx.Data.(*nazuna.Repository)
+	return repo.Push()
+}
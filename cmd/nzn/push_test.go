@@ -0,0 +1,91 @@
+//
+// nazuna/cmd/nzn :: push_test.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package main
+
+import (
+	"testing"
+
+	"github.com/hattya/go.cli"
+)
+
+func TestPush(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", "--bare", "$public/nazuna.git"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "remote", "add", "origin", "$public/nazuna.git"},
+		},
+		{
+			cmd: []string{"nzn", "commit", "-m", "."},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "push", "-q", "-u", "origin", "HEAD"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"nzn", "commit", "-m", "a"},
+		},
+		{
+			cmd: []string{"nzn", "push"},
+		},
+		{
+			cmd: []string{"git", "--git-dir", "$public/nazuna.git", "log", "--format=%s"},
+			out: cli.Dedent(`
+				a
+				.
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPushError(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"nzn", "push"},
+			out: cli.Dedent(`
+				nzn: no repository found in '.+' \(\.nzn not found\)! (re)
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "push", "_"},
+			out: cli.Dedent(`
+				nzn: invalid arguments
+				[1]
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,89 @@
+//
+// nazuna/cmd/nzn :: scripts.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hattya/go.cli"
+	"github.com/hattya/nazuna"
+)
+
+func init() {
+	flags := cli.NewFlagSet()
+	flags.Bool("list", false, "list scripts")
+	flags.Bool("rerun", false, "run the script <name> again")
+
+	app.Add(&cli.Command{
+		Name: []string{"scripts"},
+		Usage: []string{
+			"--list",
+			"--rerun <name>",
+		},
+		Desc: strings.TrimSpace(cli.Dedent(`
+			manage setup scripts
+
+			  Scripts in .nzn/scripts/once and .nzn/scripts/onchange of layers are run by
+			  update. The results are recorded in the working copy.
+
+			  scripts can list the scripts of the working copy by --list flag with the
+			  following statuses:
+
+			    ok         = done
+			    pending    = not run yet
+			    changed    = changed after the last run
+			    failed     = failed at the last run
+
+			  scripts can run the script <name> again by --rerun flag. <name> is shown as
+			  <layer>:<path> by --list flag.
+		`)),
+		Flags:  flags,
+		Action: scripts,
+		Data:   true,
+	})
+}
+
+func scripts(ctx *cli.Context) error {
+	repo := ctx.Data.(*nazuna.Repository)
+	wc, err := repo.WC()
+	if err != nil {
+		return err
+	}
+	list, err := wc.Scripts()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case ctx.Bool("list"):
+		if len(ctx.Args) != 0 {
+			return cli.ErrArgs
+		}
+		for _, s := range list {
+			app.Printf("%-10v %v\n", wc.ScriptStatus(s), s)
+		}
+	case ctx.Bool("rerun"):
+		if len(ctx.Args) != 1 {
+			return cli.ErrArgs
+		}
+		for _, s := range list {
+			if s.String() == ctx.Args[0] {
+				app.Printf("run %v\n", s)
+				err := wc.RunScript(s)
+				if ferr := wc.Flush(); err == nil {
+					err = ferr
+				}
+				return err
+			}
+		}
+		return fmt.Errorf("script '%v' does not exist!", ctx.Args[0])
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+//
+// nazuna/cmd/nzn :: scripts_test.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package main
+
+import (
+	"testing"
+
+	"github.com/hattya/go.cli"
+)
+
+func TestScripts(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.vimrc"},
+		},
+		{
+			cmd: []string{"mkdir", ".nzn/r/a/.nzn/scripts/once"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.nzn/scripts/once/fonts.sh"},
+		},
+		{
+			cmd: []string{"mkdir", ".nzn/r/a/.nzn/scripts/onchange"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.nzn/scripts/onchange/packages.sh"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "add", "."},
+		},
+		{
+			cmd: []string{"nzn", "scripts", "--list"},
+			out: cli.Dedent(`
+				pending    a:once/fonts.sh
+				pending    a:onchange/packages.sh
+			`),
+		},
+		{
+			cmd: []string{"nzn", "update", "--dry-run"},
+			out: cli.Dedent(`
+				link .vimrc --> a
+				1 updated, 0 removed, 0 failed
+				run a:once/fonts.sh
+				run a:onchange/packages.sh
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestScriptsError(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "scripts", "--list", "a:once/fonts.sh"},
+			out: cli.Dedent(`
+				nzn: invalid arguments
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"nzn", "scripts", "--rerun"},
+			out: cli.Dedent(`
+				nzn: invalid arguments
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"nzn", "scripts", "--rerun", "a:once/fonts.sh"},
+			out: cli.Dedent(`
+				nzn: script 'a:once/fonts.sh' does not exist!
+				[1]
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,73 @@
+//
+// nazuna/cmd/nzn :: status.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package main
+
+import (
+	"strings"
+
+	"github.com/hattya/go.cli"
+	"github.com/hattya/nazuna"
+)
+
+func init() {
+	flags := cli.NewFlagSet()
+	flags.String("l, layer", "", "layer name")
+
+	app.Add(&cli.Command{
+		Name:  []string{"status"},
+		Usage: "[-l <layer>]",
+		Desc: strings.TrimSpace(cli.Dedent(`
+			show changed files in the repository
+
+			  status shows changed files in the repository with the following codes:
+
+			    M = modified
+			    A = added
+			    R = removed
+			    ! = missing
+			    ? = not tracked
+			    U = unresolved
+
+			  The files in layers are shown as <layer>:<path>. If --layer flag is
+			  specified, only the files in the layer are shown.
+		`)),
+		Flags:  flags,
+		Action: status,
+		Data:   true,
+	})
+}
+
+func status(ctx *cli.Context) error {
+	if len(ctx.Args) != 0 {
+		return cli.ErrArgs
+	}
+	repo := ctx.Data.(*nazuna.Repository)
+	var name string
+	if ctx.String("layer") != "" {
+		l, err := repo.LayerOf(ctx.String("layer"))
+		if err != nil {
+			return err
+		}
+		name = l.Path()
+	}
+	list, err := repo.Status()
+	if err != nil {
+		return err
+	}
+	for _, st := range list {
+		switch {
+		case name != "" && st.Layer != name && !strings.HasPrefix(st.Layer, name+"/"):
+		case st.Layer != "":
+			app.Printf("%c %v:%v\n", st.Code, st.Layer, st.Path)
+		default:
+			app.Printf("%c %v\n", st.Code, st.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+//
+// nazuna/cmd/nzn :: status_test.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package main
+
+import (
+	"testing"
+
+	"github.com/hattya/go.cli"
+)
+
+func TestStatus(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "status"},
+			out: cli.Dedent(`
+				A nazuna.json
+			`),
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "b/1"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.vimrc"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/b/1/.vimrc"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "add", "a"},
+		},
+		{
+			cmd: []string{"nzn", "status"},
+			out: cli.Dedent(`
+				A a:.vimrc
+				A nazuna.json
+				? b/1:.vimrc
+			`),
+		},
+		{
+			cmd: []string{"nzn", "status", "-l", "b"},
+			out: cli.Dedent(`
+				? b/1:.vimrc
+			`),
+		},
+		{
+			cmd: []string{"nzn", "status", "-l", "a"},
+			out: cli.Dedent(`
+				A a:.vimrc
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"nzn", "status"},
+			out: cli.Dedent(`
+				nzn: no repository found in '.+' \(\.nzn not found\)! (re)
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "status", "-l", "a"},
+			out: cli.Dedent(`
+				nzn: layer 'a' does not exist!
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"nzn", "status", "_"},
+			out: cli.Dedent(`
+				nzn: invalid arguments
+				[1]
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,7 +1,7 @@
 //
 // nazuna/cmd/nzn :: subrepo.go
 //
-//   Copyright (c) 2013-2020 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -9,8 +9,9 @@
 package main
 
 import (
+	"fmt"
+	"io"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/hattya/go.cli"
@@ -21,25 +22,72 @@ func init() {
 	flags := cli.NewFlagSet()
 	flags.String("l, layer", "", "layer name")
 	flags.Bool("a, add", false, "add <repository> to <path>")
+	flags.String("rev", "", "revision to check out")
+	flags.String("vcs", "", "vcs type")
+	flags.MetaVar("vcs", " <type>")
+	flags.String("build", "", "command to build the repository")
+	flags.MetaVar("build", " <command>")
 	flags.Bool("u, update", false, "clone or update repositories")
+	flags.Bool("lock", false, "record revisions of repositories")
+	flags.Bool("list", false, "list repositories")
+	flags.Bool("r, remove", false, "remove the repository at <path>")
+	flags.Bool("purge", false, "remove the clone of the repository")
+	flags.Bool("force", false, "remove clones which have uncommitted changes")
+	flags.Bool("prune", false, "remove clones which are not used")
+	flags.Bool("y, yes", false, "do not prompt for confirmation")
 
 	app.Add(&cli.Command{
 		Name: []string{"subrepo"},
 		Usage: []string{
-			"-l <layer> -a <repository> <path>",
+			"-l <layer> -a [--rev <rev>] [--vcs <type>] [--build <command>] <repository> <path>",
 			"-u",
+			"--lock",
+			"--list",
+			"[-l <layer>] -r [--purge [--force]] <path>",
+			"--prune [-y] [--force]",
 		},
 		Desc: strings.TrimSpace(cli.Dedent(`
 			manage subrepositories
 
-			  subrepo is used to manage external repositories.
+			  subrepo is used to manage external repositories. <path> is relative to the
+			  current directory, or to the working copy when the current directory is
+			  outside of it.
 
 			  subrepo can associate <repository> to <path> by --add flag. If <path> ends
 			  with a path separator, it will be associated as the basename of <repository>
-			  under <path>.
+			  under <path>. If --rev flag is specified, <repository> will be pinned to
+			  <rev>, which is a branch, tag or commit.
+
+			  <repository> is either a short form like github.com/<user>/<repo>, or a URL
+			  of a Git or Mercurial repository. Supported URLs are https://, ssh://,
+			  git://, file://, scp-like syntax (user@host:path) and absolute paths. The
+			  vcs type is detected by the scheme, the ".git" suffix or the repository
+			  itself. If it cannot be detected, specify it by --vcs flag. Other short
+			  forms are resolved by <meta name="go-import"> tags like "go get", and the
+			  results are cached in .nzn/cache.json.
+
+			  If <repository> has a path after the repository root like
+			  github.com/<user>/<repo>/<path>, <path> in the repository will be
+			  associated. The repositories which have the same root share a clone.
 
 			  subrepo can clone or update the repositories in the working copy by --update
-			  flag.
+			  flag. If --build flag is specified on --add, <command> will be run in the
+			  clone after it is cloned or its revision is changed.
+
+			  subrepo can record the current revisions of the repositories to nazuna.lock
+			  by --lock flag. The recorded revisions take precedence over --rev on update.
+
+			  subrepo can list the repositories in the working copy by --list flag.
+
+			  subrepo can remove the repository at <path> by --remove flag. If --layer
+			  flag is not specified, the layer which provides <path> to the working copy
+			  is used. The clone of the repository is also removed by --purge flag when
+			  it is not used by other paths and it has no uncommitted changes. If --force
+			  flag is specified, it is removed even if it has uncommitted changes.
+
+			  subrepo can remove the clones which are not used by any layers by --prune
+			  flag. The clones which have uncommitted changes or untracked files are not
+			  removed unless --force flag is specified.
 		`)),
 		Flags:  flags,
 		Action: subrepo,
@@ -68,45 +116,320 @@ func subrepo(ctx *cli.Context) error {
 		}
 		src := ctx.Args[0]
 		dst := ctx.Args[1]
-		rel, err := wc.Rel('.', dst)
+		rel, err := relPath(wc, dst)
 		if err != nil {
 			return err
 		}
 		if len(dst) > 0 && os.IsPathSeparator(dst[len(dst)-1]) {
-			dst = rel + "/" + filepath.Base(src)
+			dst = rel + "/" + nazuna.SubrepoName(src)
 		} else {
 			dst = rel
 		}
-		if _, err := l.NewSubrepo(src, dst); err != nil {
+		sub, err := l.NewSubrepo(src, dst)
+		if err != nil {
 			return err
 		}
+		sub.Rev = ctx.String("rev")
+		sub.VCS = ctx.String("vcs")
+		sub.Build = ctx.String("build")
 		return repo.Flush()
 	case ctx.Bool("update"):
 		_, err := wc.MergeLayers()
 		if err != nil {
 			return err
 		}
-		ui := newUI()
+		if _, failed := updateSubrepos(repo, wc); failed > 0 {
+			return SystemExit(1)
+		}
+	case ctx.Bool("lock"):
+		_, err := wc.MergeLayers()
+		if err != nil {
+			return err
+		}
+		lock := make(map[string]string)
 		for _, e := range wc.State.WC {
 			if e.Type != "subrepo" {
 				continue
 			}
-			app.Printf("* %v\n", e.Origin)
-			r, err := nazuna.NewRemote(ui, e.Origin)
+			r, err := newRemote(repo, e)
 			if err != nil {
 				return err
 			}
 			dst := repo.SubrepoFor(r.Root)
 			if nazuna.IsEmptyDir(dst) {
-				dst, _ = wc.Rel('.', dst)
-				err = r.Clone(wc.PathFor("/"), dst)
-			} else {
-				err = r.Update(dst)
+				return fmt.Errorf("subrepo '%v' is not cloned", e.Origin)
+			}
+			rev, err := r.Revision(dst)
+			if err != nil {
+				return err
+			}
+			app.Printf("%v %v\n", rev, e.Origin)
+			lock[r.Root] = rev
+		}
+		repo.Lock.Subrepos = lock
+		return repo.FlushLock()
+	case ctx.Bool("list"):
+		_, err := wc.MergeLayers()
+		if err != nil {
+			return err
+		}
+		for _, e := range wc.State.WC {
+			if e.Type != "subrepo" {
+				continue
+			}
+			r, err := newRemote(repo, e)
+			if err != nil {
+				return err
+			}
+			dst := repo.SubrepoFor(r.Root)
+			rel := clonePath(wc, dst)
+			app.Println(e.Format("%v --> %v"))
+			app.Printf("    layer:    %v\n", e.Layer)
+			app.Printf("    vcs:      %v\n", r.VCS)
+			if nazuna.IsEmptyDir(dst) {
+				app.Printf("    clone:    %v (not cloned)\n", rel)
+				continue
+			}
+			app.Printf("    clone:    %v\n", rel)
+			rev, err := r.Revision(dst)
+			if err != nil {
+				return err
+			}
+			app.Printf("    revision: %v\n", rev)
+		}
+	case ctx.Bool("remove"):
+		if len(ctx.Args) != 1 {
+			return cli.ErrArgs
+		}
+		rel, err := relPath(wc, ctx.Args[0])
+		if err != nil {
+			return err
+		}
+		var e *nazuna.Entry
+		for _, we := range wc.State.WC {
+			if we.Path == rel && we.Type == "subrepo" {
+				e = we
+				break
+			}
+		}
+		name := ctx.String("layer")
+		if name == "" {
+			if e == nil {
+				return fmt.Errorf("subrepo '%v' does not exist!", rel)
+			}
+			name = e.Layer
+		}
+		l, err := repo.LayerOf(name)
+		if err != nil {
+			return err
+		}
+		sub, err := l.RemoveSubrepo(rel)
+		if err != nil {
+			return err
+		}
+		if err := repo.Flush(); err != nil {
+			return err
+		}
+
+		if e != nil && e.Layer == l.Path() {
+			if wc.IsLink(e.Path) && wc.LinksTo(e.Path, repo.SubrepoFor(e.Origin)) {
+				app.Println(e.Format("unlink %v -/- %v"))
+				if err := wc.Unlink(e.Path); err != nil {
+					return wc.Errorf(err)
+				}
+			}
+			for i, we := range wc.State.WC {
+				if we == e {
+					wc.State.WC = append(wc.State.WC[:i], wc.State.WC[i+1:]...)
+					break
+				}
+			}
+			if err := wc.Flush(); err != nil {
+				return err
+			}
+		}
+		if !ctx.Bool("purge") {
+			return nil
+		}
+
+		r, err := repo.NewRemote(sub.Src)
+		if err != nil {
+			return err
+		}
+		for _, s := range repo.Subrepos() {
+			if o, err := repo.NewRemote(s.Src); err == nil && o.Root == r.Root {
+				return nil
+			}
+		}
+		dst := repo.SubrepoFor(r.Root)
+		if nazuna.IsEmptyDir(dst) {
+			return nil
+		}
+		rel = clonePath(wc, dst)
+		if !ctx.Bool("force") {
+			switch dirty, err := isDirty(dst); {
+			case err != nil:
+				return err
+			case dirty:
+				app.Errorf("warning: %v has uncommitted changes\n", rel)
+				return nil
 			}
+		}
+		app.Printf("remove %v\n", rel)
+		return repo.RemoveClone(r.Root)
+	case ctx.Bool("prune"):
+		used := make(map[string]bool)
+		for _, sub := range repo.Subrepos() {
+			r, err := repo.NewRemote(sub.Src)
 			if err != nil {
 				return err
 			}
+			used[r.Root] = true
+		}
+		clones, err := repo.Clones()
+		if err != nil {
+			return err
+		}
+		var list []string
+		var total int64
+		for _, p := range clones {
+			if used[p] {
+				continue
+			}
+			dst := repo.SubrepoFor(p)
+			rel := clonePath(wc, dst)
+			if !ctx.Bool("force") {
+				switch dirty, err := isDirty(dst); {
+				case err != nil:
+					return err
+				case dirty:
+					app.Errorf("warning: %v has uncommitted changes\n", rel)
+					continue
+				}
+			}
+			n, err := du(dst)
+			if err != nil {
+				return err
+			}
+			app.Printf("%v (%v)\n", rel, formatSize(n))
+			list = append(list, p)
+			total += n
+		}
+		switch {
+		case len(list) == 0:
+			return nil
+		case !ctx.Bool("yes") && !confirm(fmt.Sprintf("remove %d clones (%v)?", len(list), formatSize(total))):
+			return nil
+		}
+		for _, p := range list {
+			rel := clonePath(wc, repo.SubrepoFor(p))
+			app.Printf("remove %v\n", rel)
+			if err := repo.RemoveClone(p); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
+
+func updateSubrepos(repo *nazuna.Repository, wc *nazuna.WC) (n, failed int) {
+	done := make(map[string]bool)
+	for _, e := range wc.State.WC {
+		if e.Type != "subrepo" {
+			continue
+		}
+		app.Printf("* %v\n", e.Origin)
+		r, err := newRemote(repo, e)
+		if err != nil {
+			app.Errorln("error: subrepo:", err)
+			failed++
+			continue
+		}
+		if _, ok := done[r.Root]; !ok {
+			if rev, ok := repo.Lock.Subrepos[r.Root]; ok {
+				r.Rev = rev
+			}
+			dst := repo.SubrepoFor(r.Root)
+			var rev string
+			if nazuna.IsEmptyDir(dst) {
+				err = r.Clone(wc.PathFor("/"), clonePath(wc, dst))
+			} else {
+				if r.Build != "" {
+					rev, _ = r.Revision(dst)
+				}
+				err = r.Update(dst)
+			}
+			done[r.Root] = err == nil
+			if err != nil {
+				app.Errorln("error: subrepo:", err)
+				failed++
+				continue
+			}
+			// build after clone or when the revision is changed
+			if r.Build != "" {
+				if cur, _ := r.Revision(dst); rev == "" || cur != rev {
+					if err := r.RunBuild(dst); err != nil {
+						app.Errorln("error: subrepo:", err)
+						failed++
+					}
+				}
+			}
+		} else if !done[r.Root] {
+			continue
+		}
+		if r.Path != "" && !nazuna.IsDir(repo.SubrepoFor(e.Origin)) {
+			app.Errorf("warning: subrepo: '%v' does not exist in %v\n", strings.TrimPrefix(r.Path, "/"), r.Root)
+		}
+	}
+	for _, ok := range done {
+		if ok {
+			n++
+		}
+	}
+	return
+}
+
+func newRemote(repo *nazuna.Repository, e *nazuna.Entry) (*nazuna.Remote, error) {
+	r, err := repo.NewRemote(e.Origin)
+	if err != nil {
+		return nil, err
+	}
+	l, err := repo.LayerOf(e.Layer)
+	if err != nil {
+		return nil, err
+	}
+	if sub := l.SubrepoOf(e.Origin); sub != nil {
+		r.Rev = sub.Rev
+		if sub.VCS != "" {
+			r.VCS = sub.VCS
+		}
+		r.Build = sub.Build
+	}
+	return r, nil
+}
+
+// clonePath returns the path of the clone relative to the working copy, or
+// the absolute path when it is not under the working copy
+func clonePath(wc *nazuna.WC, dst string) string {
+	if rel, err := wc.Rel('/', dst); err == nil {
+		return rel
+	}
+	return dst
+}
+
+// isDirty reports whether the clone has uncommitted changes or untracked
+// files
+func isDirty(dst string) (bool, error) {
+	vcs, err := nazuna.VCSFor(newUI(), dst)
+	if err != nil {
+		return false, err
+	}
+	if c, ok := vcs.(io.Closer); ok {
+		defer c.Close()
+	}
+	st, err := vcs.Status()
+	if err != nil {
+		return false, err
+	}
+	return len(st) > 0, nil
+}
@@ -1,7 +1,7 @@
 //
 // nazuna/cmd/nzn :: subrepo_test.go
 //
-//   Copyright (c) 2013-2022 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -184,6 +184,763 @@ func TestSubrepo(t *testing.T) {
 	}
 }
 
+func TestSubrepoRev(t *testing.T) {
+	sh, err := newShell(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewTLSServer(http.FileServer(http.Dir(filepath.Join(sh.dir, "public"))))
+	defer ts.Close()
+
+	sh.gitconfig["http.sslVerify"] = "false"
+	sh.gitconfig["advice.detachedHead"] = "false"
+	sh.gitconfig["url."+ts.URL+"/vim-pathogen/.git.insteadOf"] = "https://github.com/tpope/vim-pathogen"
+
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", "$public/vim-pathogen"},
+		},
+		{
+			cmd: []string{"cd", "$public/vim-pathogen"},
+		},
+		{
+			cmd: []string{"mkdir", "autoload"},
+		},
+		{
+			cmd: []string{"touch", "autoload/pathogen.vim"},
+		},
+		{
+			cmd: []string{"git", "add", "."},
+		},
+		{
+			cmd: []string{"git", "commit", "-qm", "."},
+		},
+		{
+			cmd: []string{"git", "tag", "v1.0"},
+		},
+		{
+			cmd: []string{"touch", "README.markdown"},
+		},
+		{
+			cmd: []string{"git", "add", "."},
+		},
+		{
+			cmd: []string{"git", "commit", "-qm", "."},
+		},
+		{
+			cmd: []string{"git", "update-server-info"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-a", "--rev", "v1.0", "github.com/tpope/vim-pathogen", ".vim/bundle/"},
+		},
+		{
+			cmd: []string{"cat", ".nzn/r/nazuna.json"},
+			out: cli.Dedent(`
+				[
+				  {
+				    "name": "a",
+				    "subrepos": {
+				      ".vim/bundle": [
+				        {
+				          "src": "github.com/tpope/vim-pathogen",
+				          "rev": "v1.0"
+				        }
+				      ]
+				    }
+				  }
+				]
+			`),
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "--lock"},
+			out: cli.Dedent(`
+				nzn: subrepo 'github.com/tpope/vim-pathogen' is not cloned
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-u"},
+			out: cli.Dedent(`
+				* github.com/tpope/vim-pathogen
+				Cloning into '.nzn/sub/github.com/tpope/vim-pathogen'...
+			`),
+		},
+		{
+			cmd: []string{"ls", ".nzn/sub/github.com/tpope/vim-pathogen"},
+			out: cli.Dedent(`
+				.git/
+				autoload/
+			`),
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-u"},
+			out: cli.Dedent(`
+				* github.com/tpope/vim-pathogen
+			`),
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "--lock"},
+			out: cli.Dedent(`
+				[[:xdigit:]]{40} github.com/tpope/vim-pathogen (re)
+			`),
+		},
+		{
+			cmd: []string{"cat", ".nzn/r/nazuna.lock"},
+			out: cli.Dedent(`
+				{
+				  "subrepos": {
+				    "github.com/tpope/vim-pathogen": "[[:xdigit:]]{40}" (re)
+				  }
+				}
+			`),
+		},
+	}
+	if err := sh.run(s); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubrepoBuild(t *testing.T) {
+	sh, err := newShell(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewTLSServer(http.FileServer(http.Dir(filepath.Join(sh.dir, "public"))))
+	defer ts.Close()
+
+	sh.gitconfig["http.sslVerify"] = "false"
+	sh.gitconfig["merge.stat"] = "false"
+	sh.gitconfig["url."+ts.URL+"/vim-pathogen/.git.insteadOf"] = "https://github.com/tpope/vim-pathogen"
+	sh.gitconfig["url."+ts.URL+"/vim-surround/.git.insteadOf"] = "https://github.com/tpope/vim-surround"
+
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", "$public/vim-pathogen"},
+		},
+		{
+			cmd: []string{"cd", "$public/vim-pathogen"},
+		},
+		{
+			cmd: []string{"touch", "README.markdown"},
+		},
+		{
+			cmd: []string{"git", "add", "."},
+		},
+		{
+			cmd: []string{"git", "commit", "-qm", "v1"},
+		},
+		{
+			cmd: []string{"git", "update-server-info"},
+		},
+		{
+			cmd: []string{"cd", "$tempdir"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", "$public/vim-surround"},
+		},
+		{
+			cmd: []string{"cd", "$public/vim-surround"},
+		},
+		{
+			cmd: []string{"touch", "README.markdown"},
+		},
+		{
+			cmd: []string{"git", "add", "."},
+		},
+		{
+			cmd: []string{"git", "commit", "-qm", "."},
+		},
+		{
+			cmd: []string{"git", "update-server-info"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-a", "--build", "git log -1 --format=%s", "github.com/tpope/vim-pathogen", ".vim/bundle/"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-a", "--build", "exit 1", "github.com/tpope/vim-surround", ".vim/bundle/"},
+		},
+		{
+			cmd: []string{"cat", ".nzn/r/nazuna.json"},
+			out: cli.Dedent(`
+				[
+				  {
+				    "name": "a",
+				    "subrepos": {
+				      ".vim/bundle": [
+				        {
+				          "src": "github.com/tpope/vim-pathogen",
+				          "build": "git log -1 --format=%s"
+				        },
+				        {
+				          "src": "github.com/tpope/vim-surround",
+				          "build": "exit 1"
+				        }
+				      ]
+				    }
+				  }
+				]
+			`),
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-u"},
+			out: cli.Dedent(`
+				* github.com/tpope/vim-pathogen
+				Cloning into '.nzn/sub/github.com/tpope/vim-pathogen'...
+				vim-pathogen: v1
+				* github.com/tpope/vim-surround
+				Cloning into '.nzn/sub/github.com/tpope/vim-surround'...
+				error: subrepo: build of github.com/tpope/vim-surround failed: exit status 1
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-u"},
+			out: cli.Dedent(`
+				* github.com/tpope/vim-pathogen
+				Already up.to.date\. (re)
+				* github.com/tpope/vim-surround
+				Already up.to.date\. (re)
+			`),
+		},
+		{
+			cmd: []string{"cd", "$public/vim-pathogen"},
+		},
+		{
+			cmd: []string{"mkdir", "autoload"},
+		},
+		{
+			cmd: []string{"touch", "autoload/pathogen.vim"},
+		},
+		{
+			cmd: []string{"git", "add", "."},
+		},
+		{
+			cmd: []string{"git", "commit", "-qm", "v2"},
+		},
+		{
+			cmd: []string{"git", "update-server-info"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-u"},
+			out: cli.Dedent(`
+				* github.com/tpope/vim-pathogen
+				From https://127.0.0.1:\d+/vim-pathogen (re)
+				   [[:alnum:]]+\.\.[[:alnum:]]+  master\s+ -> origin/master (re)
+				Updating [[:alnum:]]+\.\.[[:alnum:]]+ (re)
+				Fast-forward
+				vim-pathogen: v2
+				* github.com/tpope/vim-surround
+				Already up.to.date\. (re)
+			`),
+		},
+	}
+	if err := sh.run(s); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubrepoTarget(t *testing.T) {
+	sh, err := newShell(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewTLSServer(http.FileServer(http.Dir(filepath.Join(sh.dir, "public"))))
+	defer ts.Close()
+
+	sh.gitconfig["http.sslVerify"] = "false"
+	sh.gitconfig["url."+ts.URL+"/vim-pathogen/.git.insteadOf"] = "https://github.com/tpope/vim-pathogen"
+
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", "$public/vim-pathogen"},
+		},
+		{
+			cmd: []string{"cd", "$public/vim-pathogen"},
+		},
+		{
+			cmd: []string{"touch", "README.markdown"},
+		},
+		{
+			cmd: []string{"git", "add", "."},
+		},
+		{
+			cmd: []string{"git", "commit", "-qm", "."},
+		},
+		{
+			cmd: []string{"git", "update-server-info"},
+		},
+		{
+			cmd: []string{"cd", "$tempdir"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git", "--target", "$wc", "$public/repo"},
+		},
+		{
+			cmd: []string{"cd", "$public/repo"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-a", "github.com/tpope/vim-pathogen", ".vim/bundle/"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-u"},
+			out: cli.Dedent(`
+				* github.com/tpope/vim-pathogen
+				Cloning into '.+` + quote("/repo/.nzn/sub/github.com/tpope/vim-pathogen") + `'\.\.\. (re)
+			`),
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				link .vim/bundle/vim-pathogen --> github.com/tpope/vim-pathogen
+				1 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"ls", "$wc/.vim/bundle"},
+			out: cli.Dedent(`
+				vim-pathogen
+			`),
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-r", "--purge", ".vim/bundle/vim-pathogen"},
+			out: cli.Dedent(`
+				unlink .vim/bundle/vim-pathogen -/- github.com/tpope/vim-pathogen
+				remove .+` + quote("/repo/.nzn/sub/github.com/tpope/vim-pathogen") + ` (re)
+			`),
+		},
+		{
+			cmd: []string{"ls", "$wc"},
+		},
+	}
+	if err := sh.run(s); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubrepoPath(t *testing.T) {
+	sh, err := newShell(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewTLSServer(http.FileServer(http.Dir(filepath.Join(sh.dir, "public"))))
+	defer ts.Close()
+
+	sh.gitconfig["http.sslVerify"] = "false"
+	sh.gitconfig["url."+ts.URL+"/vim-pathogen/.git.insteadOf"] = "https://github.com/tpope/vim-pathogen"
+
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", "$public/vim-pathogen"},
+		},
+		{
+			cmd: []string{"cd", "$public/vim-pathogen"},
+		},
+		{
+			cmd: []string{"mkdir", "autoload"},
+		},
+		{
+			cmd: []string{"touch", "autoload/pathogen.vim"},
+		},
+		{
+			cmd: []string{"mkdir", "doc"},
+		},
+		{
+			cmd: []string{"touch", "doc/pathogen.txt"},
+		},
+		{
+			cmd: []string{"git", "add", "."},
+		},
+		{
+			cmd: []string{"git", "commit", "-qm", "."},
+		},
+		{
+			cmd: []string{"git", "update-server-info"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-a", "github.com/tpope/vim-pathogen/autoload", ".vim/"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-a", "github.com/tpope/vim-pathogen/plugin", ".vim/"},
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				0 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-u"},
+			out: cli.Dedent(`
+				* github.com/tpope/vim-pathogen/autoload
+				Cloning into '.nzn/sub/github.com/tpope/vim-pathogen'...
+				* github.com/tpope/vim-pathogen/plugin
+				warning: subrepo: 'plugin' does not exist in github.com/tpope/vim-pathogen
+			`),
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				link .vim/autoload --> github.com/tpope/vim-pathogen/autoload
+				1 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"ls", ".vim/autoload"},
+			out: cli.Dedent(`
+				pathogen.vim
+			`),
+		},
+	}
+	if err := sh.run(s); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubrepoURL(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", "$public/vim-pathogen"},
+		},
+		{
+			cmd: []string{"cd", "$public/vim-pathogen"},
+		},
+		{
+			cmd: []string{"touch", "README.markdown"},
+		},
+		{
+			cmd: []string{"git", "add", "."},
+		},
+		{
+			cmd: []string{"git", "commit", "-qm", "."},
+		},
+		{
+			cmd: []string{"cd", "$tempdir"},
+		},
+		{
+			cmd: []string{"git", "clone", "-q", "--bare", "$public/vim-pathogen", "$public/vim-pathogen.git"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-a", "$public/vim-pathogen.git", ".vim/bundle/"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-u"},
+			out: cli.Dedent(`
+				\* .+` + quote("/public/vim-pathogen.git") + ` (re)
+				Cloning into '\.nzn/sub/file/.+/public/vim-pathogen'\.\.\. (re)
+				done.
+			`),
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				link .vim/bundle/vim-pathogen --> .+` + quote("/public/vim-pathogen.git") + ` (re)
+				1 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"ls", ".vim/bundle/vim-pathogen"},
+			out: cli.Dedent(`
+				.git/
+				README.markdown
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubrepoRemove(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-a", "github.com/tpope/vim-pathogen", ".vim/bundle/"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-a", "github.com/kien/ctrlp.vim", ".vim/bundle/"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", ".nzn/sub/github.com/tpope/vim-pathogen"},
+		},
+		{
+			cmd: []string{"cd", ".nzn/sub/github.com/tpope/vim-pathogen"},
+		},
+		{
+			cmd: []string{"touch", "README.markdown"},
+		},
+		{
+			cmd: []string{"git", "add", "."},
+		},
+		{
+			cmd: []string{"git", "commit", "-qm", "."},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "--list"},
+			out: cli.Dedent(`
+				.vim/bundle/ctrlp.vim --> github.com/kien/ctrlp.vim
+				    layer:    a
+				    vcs:      git
+				    clone:    .nzn/sub/github.com/kien/ctrlp.vim (not cloned)
+				.vim/bundle/vim-pathogen --> github.com/tpope/vim-pathogen
+				    layer:    a
+				    vcs:      git
+				    clone:    .nzn/sub/github.com/tpope/vim-pathogen
+				    revision: [[:xdigit:]]{40} (re)
+			`),
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				link .vim/bundle/vim-pathogen --> github.com/tpope/vim-pathogen
+				1 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-r"},
+			out: cli.Dedent(`
+				nzn: invalid arguments
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"cd", ".nzn/sub/github.com/tpope/vim-pathogen"},
+		},
+		{
+			cmd: []string{"touch", "CONTRIBUTING.markdown"},
+		},
+		{
+			cmd: []string{"git", "add", "."},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-r", "--purge", ".vim/bundle/vim-pathogen"},
+			out: cli.Dedent(`
+				unlink .vim/bundle/vim-pathogen -/- github.com/tpope/vim-pathogen
+				warning: .nzn/sub/github.com/tpope/vim-pathogen has uncommitted changes
+			`),
+		},
+		{
+			cmd: []string{"ls", ".nzn/sub/github.com/tpope"},
+			out: cli.Dedent(`
+				vim-pathogen/
+			`),
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-a", "github.com/tpope/vim-pathogen", ".vim/bundle/"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-r", "--purge", "--force", ".vim/bundle/vim-pathogen"},
+			out: cli.Dedent(`
+				remove .nzn/sub/github.com/tpope/vim-pathogen
+			`),
+		},
+		{
+			cmd: []string{"ls", ".nzn/sub/github.com/tpope"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-r", ".vim/bundle/vim-pathogen"},
+			out: cli.Dedent(`
+				nzn: subrepo '.vim/bundle/vim-pathogen' does not exist!
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-r", ".vim/bundle/vim-pathogen"},
+			out: cli.Dedent(`
+				nzn: subrepo '.vim/bundle/vim-pathogen' does not exist!
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-r", ".vim/bundle/ctrlp.vim"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "--list"},
+		},
+		{
+			cmd: []string{"cat", ".nzn/r/nazuna.json"},
+			out: cli.Dedent(`
+				[
+				  {
+				    "name": "a"
+				  }
+				]
+			`),
+		},
+		{
+			cmd: []string{"cat", ".nzn/state.json"},
+			out: cli.Dedent(`
+				{}
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubrepoPrune(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-a", "github.com/tpope/vim-pathogen", ".vim/bundle/"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "--prune", "-y"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", ".nzn/sub/github.com/tpope/vim-pathogen"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", ".nzn/sub/github.com/kien/ctrlp.vim"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", ".nzn/sub/github.com/mattn/gist-vim"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", ".nzn/sub/github.com/tyru/open-browser.vim"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/sub/github.com/mattn/gist-vim/README.mkd"},
+		},
+		{
+			cmd: []string{"cd", ".nzn/sub/github.com/kien/ctrlp.vim"},
+		},
+		{
+			cmd: []string{"touch", "readme.md"},
+		},
+		{
+			cmd: []string{"git", "add", "."},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "--prune", "-y"},
+			out: cli.Dedent(`
+				warning: .nzn/sub/github.com/kien/ctrlp.vim has uncommitted changes
+				warning: .nzn/sub/github.com/mattn/gist-vim has uncommitted changes
+				.nzn/sub/github.com/tyru/open-browser.vim \(.+\) (re)
+				remove .nzn/sub/github.com/tyru/open-browser.vim
+			`),
+		},
+		{
+			cmd: []string{"ls", ".nzn/sub/github.com"},
+			out: cli.Dedent(`
+				kien/
+				mattn/
+				tpope/
+			`),
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "--prune", "-y", "--force"},
+			out: cli.Dedent(`
+				.nzn/sub/github.com/kien/ctrlp.vim \(.+\) (re)
+				.nzn/sub/github.com/mattn/gist-vim \(.+\) (re)
+				remove .nzn/sub/github.com/kien/ctrlp.vim
+				remove .nzn/sub/github.com/mattn/gist-vim
+			`),
+		},
+		{
+			cmd: []string{"ls", ".nzn/sub/github.com"},
+			out: cli.Dedent(`
+				tpope/
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestSubrepoError(t *testing.T) {
 	s := script{
 		{
@@ -219,25 +976,70 @@ func TestSubrepoError(t *testing.T) {
 			cmd: []string{"nzn", "subrepo", "-a"},
 			out: cli.Dedent(`
 				nzn subrepo: --layer flag is required
-				usage: nzn subrepo -l <layer> -a <repository> <path>
+				usage: nzn subrepo -l <layer> -a [--rev <rev>] [--vcs <type>] [--build <command>] <repository> <path>
 				   or: nzn subrepo -u
+				   or: nzn subrepo --lock
+				   or: nzn subrepo --list
+				   or: nzn subrepo [-l <layer>] -r [--purge [--force]] <path>
+				   or: nzn subrepo --prune [-y] [--force]
 
 				manage subrepositories
 
-				  subrepo is used to manage external repositories.
+				  subrepo is used to manage external repositories. <path> is relative to the
+				  current directory, or to the working copy when the current directory is
+				  outside of it.
 
 				  subrepo can associate <repository> to <path> by --add flag. If <path> ends
 				  with a path separator, it will be associated as the basename of <repository>
-				  under <path>.
+				  under <path>. If --rev flag is specified, <repository> will be pinned to
+				  <rev>, which is a branch, tag or commit.
+
+				  <repository> is either a short form like github.com/<user>/<repo>, or a URL
+				  of a Git or Mercurial repository. Supported URLs are https://, ssh://,
+				  git://, file://, scp-like syntax (user@host:path) and absolute paths. The
+				  vcs type is detected by the scheme, the ".git" suffix or the repository
+				  itself. If it cannot be detected, specify it by --vcs flag. Other short
+				  forms are resolved by <meta name="go-import"> tags like "go get", and the
+				  results are cached in .nzn/cache.json.
+
+				  If <repository> has a path after the repository root like
+				  github.com/<user>/<repo>/<path>, <path> in the repository will be
+				  associated. The repositories which have the same root share a clone.
 
 				  subrepo can clone or update the repositories in the working copy by --update
-				  flag.
+				  flag. If --build flag is specified on --add, <command> will be run in the
+				  clone after it is cloned or its revision is changed.
+
+				  subrepo can record the current revisions of the repositories to nazuna.lock
+				  by --lock flag. The recorded revisions take precedence over --rev on update.
+
+				  subrepo can list the repositories in the working copy by --list flag.
+
+				  subrepo can remove the repository at <path> by --remove flag. If --layer
+				  flag is not specified, the layer which provides <path> to the working copy
+				  is used. The clone of the repository is also removed by --purge flag when
+				  it is not used by other paths and it has no uncommitted changes. If --force
+				  flag is specified, it is removed even if it has uncommitted changes.
+
+				  subrepo can remove the clones which are not used by any layers by --prune
+				  flag. The clones which have uncommitted changes or untracked files are not
+				  removed unless --force flag is specified.
 
 				options:
 
 				  -a, --add              add <repository> to <path>
+				  --build <command>      command to build the repository
+				  --force                remove clones which have uncommitted changes
 				  -l, --layer <layer>    layer name
+				  --list                 list repositories
+				  --lock                 record revisions of repositories
+				  --prune                remove clones which are not used
+				  --purge                remove the clone of the repository
+				  -r, --remove           remove the repository at <path>
+				  --rev <rev>            revision to check out
 				  -u, --update           clone or update repositories
+				  --vcs <type>           vcs type
+				  -y, --yes              do not prompt for confirmation
 
 				[2]
 			`),
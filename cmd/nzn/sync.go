@@ -0,0 +1,98 @@
+//
+// nazuna/cmd/nzn :: sync.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/hattya/go.cli"
+	"github.com/hattya/nazuna"
+)
+
+func init() {
+	app.Add(&cli.Command{
+		Name: []string{"sync"},
+		Desc: strings.TrimSpace(cli.Dedent(`
+			synchronize the working copy with the remote repository
+
+			  sync pulls changes of the repository from its remote, updates links in the
+			  working copy, and clones or updates subrepositories. If pulling changes
+			  results in conflicts, sync stops without updating the working copy. Failures
+			  of subrepositories are counted as failed, and sync continues with the others.
+
+			  Hooks and scripts of layers are run like update. See also "nzn help update".
+		`)),
+		Action: sync_,
+		Data:   true,
+	})
+}
+
+func sync_(ctx *cli.Context) error {
+	if len(ctx.Args) != 0 {
+		return cli.ErrArgs
+	}
+	repo := ctx.Data.(*nazuna.Repository)
+	if err := repo.Pull(); err != nil {
+		st, _ := repo.Status()
+		var conflicts []*nazuna.Status
+		for _, s := range st {
+			if s.Code == 'U' {
+				conflicts = append(conflicts, s)
+			}
+		}
+		if len(conflicts) == 0 {
+			return err
+		}
+		app.Errorln("conflicts:")
+		for _, s := range conflicts {
+			if s.Layer != "" {
+				app.Errorf("    %v:%v\n", s.Layer, s.Path)
+			} else {
+				app.Errorf("    %v\n", s.Path)
+			}
+		}
+		return errors.New("resolve conflicts in the repository, and run sync again!")
+	}
+	if err := repo.Reload(); err != nil {
+		return err
+	}
+
+	wc, err := repo.WC()
+	if err != nil {
+		return err
+	}
+	if err := runHooks(wc, "pre-update", nil); err != nil {
+		return err
+	}
+	removed, err := unlinkWC(repo, wc, false)
+	if err != nil {
+		return err
+	}
+	linked, failed := linkWC(repo, wc, false)
+	n, sf := updateSubrepos(repo, wc)
+	// link cloned subrepos
+	l, f := linkWC(repo, wc, false)
+	linked = append(linked, l...)
+	failed += f + sf
+
+	app.Printf("%d updated, %d removed, %d failed, %d subrepos\n", len(linked), removed, failed, n)
+	if err := wc.Flush(); err != nil {
+		return err
+	}
+	f, err = postUpdate(wc, linked)
+	if err != nil {
+		return err
+	}
+	failed += f
+	if failed > 0 {
+		return SystemExit(1)
+	}
+	return nil
+}
@@ -0,0 +1,401 @@
+//
+// nazuna/cmd/nzn :: sync_test.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hattya/go.cli"
+)
+
+func TestSync(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", "--bare", "$public/nazuna.git"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "remote", "add", "origin", "$public/nazuna.git"},
+		},
+		{
+			cmd: []string{"nzn", "commit", "-m", "."},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "push", "-q", "-u", "origin", "HEAD"},
+		},
+		{
+			cmd: []string{"cd", "$tempdir"},
+		},
+		{
+			cmd: []string{"nzn", "clone", "--vcs", "git", "$public/nazuna.git", "other"},
+			out: cli.Dedent(`
+				Cloning into '` + path("other/.nzn/r") + `'...
+				done.
+			`),
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.vimrc"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "add", "."},
+		},
+		{
+			cmd: []string{"nzn", "commit", "-m", "a"},
+		},
+		{
+			cmd: []string{"nzn", "push"},
+		},
+		{
+			cmd: []string{"cd", "$tempdir/other"},
+		},
+		{
+			cmd: []string{"nzn", "sync"},
+			out: cli.Dedent(`
+				link .vimrc --> a
+				1 updated, 0 removed, 0 failed, 0 subrepos
+			`),
+		},
+		{
+			cmd: []string{"nzn", "sync"},
+			out: cli.Dedent(`
+				0 updated, 0 removed, 0 failed, 0 subrepos
+			`),
+		},
+		{
+			cmd: []string{"ls", "."},
+			out: cli.Dedent(`
+				.nzn/
+				.vimrc
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSyncNone(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "none"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.vimrc"},
+		},
+		{
+			cmd: []string{"nzn", "sync"},
+			out: cli.Dedent(`
+				link .vimrc --> a
+				1 updated, 0 removed, 0 failed, 0 subrepos
+			`),
+		},
+		{
+			cmd: []string{"rm", ".nzn/r/a/.vimrc"},
+		},
+		{
+			cmd: []string{"nzn", "sync"},
+			out: cli.Dedent(`
+				unlink .vimrc -/- a
+				0 updated, 1 removed, 0 failed, 0 subrepos
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSyncHooks(t *testing.T) {
+	sh, err := newShell(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "none"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.vimrc"},
+		},
+		{
+			cmd: []string{"mkdir", ".nzn/r/a/.nzn/scripts/once"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.nzn/scripts/once/fonts.sh"},
+		},
+	}
+	if err := sh.run(s); err != nil {
+		t.Fatal(err)
+	}
+
+	data := `[
+  {
+    "name": "a",
+    "hooks": {
+      "pre-update": [
+        "echo pre-update"
+      ],
+      "post-link": [
+        "echo post-link"
+      ],
+      "post-update": [
+        "echo post-update"
+      ]
+    }
+  }
+]
+`
+	if err := os.WriteFile(filepath.Join(sh.dir, "wc", ".nzn", "r", "nazuna.json"), []byte(data), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	s = script{
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "sync"},
+			out: cli.Dedent(`
+				pre-update
+				link .vimrc --> a
+				1 updated, 0 removed, 0 failed, 0 subrepos
+				post-link
+				run a:once/fonts.sh
+				error: script 'a:once/fonts.sh' failed: .+ (re)
+				post-update
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"nzn", "sync"},
+			out: cli.Dedent(`
+				pre-update
+				0 updated, 0 removed, 0 failed, 0 subrepos
+				run a:once/fonts.sh
+				error: script 'a:once/fonts.sh' failed: .+ (re)
+				post-update
+				[1]
+			`),
+		},
+	}
+	if err := sh.run(s); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSyncSubrepo(t *testing.T) {
+	sh, err := newShell(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewTLSServer(http.FileServer(http.Dir(filepath.Join(sh.dir, "public"))))
+	defer ts.Close()
+
+	sh.gitconfig["http.sslVerify"] = "false"
+	sh.gitconfig["url."+ts.URL+"/vim-pathogen/.git.insteadOf"] = "https://github.com/tpope/vim-pathogen"
+	sh.gitconfig["url."+ts.URL+"/vim-surround/.git.insteadOf"] = "https://github.com/tpope/vim-surround"
+
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", "$public/vim-pathogen"},
+		},
+		{
+			cmd: []string{"cd", "$public/vim-pathogen"},
+		},
+		{
+			cmd: []string{"touch", "README.markdown"},
+		},
+		{
+			cmd: []string{"git", "add", "."},
+		},
+		{
+			cmd: []string{"git", "commit", "-qm", "."},
+		},
+		{
+			cmd: []string{"git", "update-server-info"},
+		},
+		{
+			cmd: []string{"cd", "$tempdir"},
+		},
+		{
+			cmd: []string{"git", "init", "-q", "--bare", "$public/nazuna.git"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "remote", "add", "origin", "$public/nazuna.git"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-a", "github.com/tpope/vim-pathogen", ".vim/bundle/"},
+		},
+		{
+			cmd: []string{"nzn", "subrepo", "-l", "a", "-a", "github.com/tpope/vim-surround", ".vim/bundle/"},
+		},
+		{
+			cmd: []string{"nzn", "commit", "-m", "."},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "push", "-q", "-u", "origin", "HEAD"},
+		},
+		{
+			cmd: []string{"nzn", "sync"},
+			out: cli.Dedent(`
+				* github.com/tpope/vim-pathogen
+				Cloning into '.nzn/sub/github.com/tpope/vim-pathogen'...
+				* github.com/tpope/vim-surround
+				Cloning into '.nzn/sub/github.com/tpope/vim-surround'...
+				fatal: repository '.+' not found (re)
+				error: subrepo: exit status 128
+				link .vim/bundle/vim-pathogen --> github.com/tpope/vim-pathogen
+				1 updated, 0 removed, 1 failed, 1 subrepos
+				[1]
+			`),
+		},
+	}
+	if err := sh.run(s); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSyncError(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"nzn", "sync"},
+			out: cli.Dedent(`
+				nzn: no repository found in '.+' \(\.nzn not found\)! (re)
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"git", "init", "-q", "--bare", "$public/nazuna.git"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "sync", "_"},
+			out: cli.Dedent(`
+				nzn: invalid arguments
+				[1]
+			`),
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "remote", "add", "origin", "$public/nazuna.git"},
+		},
+		{
+			cmd: []string{"nzn", "commit", "-m", "."},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "push", "-q", "-u", "origin", "HEAD"},
+		},
+		{
+			cmd: []string{"cd", "$tempdir"},
+		},
+		{
+			cmd: []string{"nzn", "clone", "--vcs", "git", "$public/nazuna.git", "other"},
+			out: cli.Dedent(`
+				Cloning into '` + path("other/.nzn/r") + `'...
+				done.
+			`),
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "b"},
+		},
+		{
+			cmd: []string{"nzn", "commit", "-m", "b"},
+		},
+		{
+			cmd: []string{"nzn", "push"},
+		},
+		{
+			cmd: []string{"cd", "$tempdir/other"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "c"},
+		},
+		{
+			cmd: []string{"nzn", "commit", "-m", "c"},
+		},
+		{
+			cmd: []string{"nzn", "sync"},
+			out: cli.Dedent(`
+				Auto-merging nazuna.json
+				CONFLICT (content): Merge conflict in nazuna.json
+				Automatic merge failed; fix conflicts and then commit the result.
+				conflicts:
+				    nazuna.json
+				nzn: resolve conflicts in the repository, and run sync again!
+				[1]
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,7 +1,7 @@
 //
 // nazuna/cmd/nzn :: update.go
 //
-//   Copyright (c) 2013-2020 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -9,6 +9,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -19,14 +20,54 @@ import (
 
 func init() {
 	flags := cli.NewFlagSet()
+	flags.Bool("n, dry-run", false, "do not update links")
+	flags.String("root", "", "alternate root directory")
+	flags.MetaVar("root", " <dir>")
 
 	app.Add(&cli.Command{
 		Name:  []string{"update"},
-		Usage: "update",
+		Usage: "update [-n] [--root <dir>]",
 		Desc: strings.TrimSpace(cli.Dedent(`
 			update working copy
 
 			  Update links in the working copy to match with the repository configuration.
+
+			  A directory is linked as a whole when only one layer has it, and it is
+			  replaced with a directory which has links to the files when another layer
+			  adds files to it, and vice versa. Layers can control this by "folding" of
+			  nazuna.json, which maps path patterns to "fold" or "no-fold". A pattern
+			  also applies to subdirectories of the matched directory. A directory is never
+			  replaced with a link when it has files which are not linked by update, and a
+			  warning is printed when files which are not tracked by the repository are
+			  hidden by unlinking a directory.
+
+			  Links are relative symbolic links by default. "linkstyle" of
+			  .nzn/config.json changes it for the working copy, and "linkstyle" of
+			  nazuna.json maps path patterns to "relative", "absolute" or "hard". Hard
+			  links are used only for files. Links are recreated when their styles are
+			  changed.
+
+			  Layers can declare commands in "hooks" of nazuna.json, which are run in the
+			  root of the working copy:
+
+			    pre-update     before updating links
+			    post-link      after updating links, only when links of the layer are
+			                   updated. NAZUNA_PATHS is set to the updated paths
+			    post-update    after updating links
+
+			  If a hook fails, update exits with status 3. Hooks are not run when --dry-run
+			  flag is specified.
+
+			  Layers can also have scripts in .nzn/scripts/once and .nzn/scripts/onchange,
+			  which are run after updating links. The scripts in once are run only once,
+			  and the scripts in onchange are run again when their contents are changed.
+			  The failed scripts are run again on the next update. Scripts are not run
+			  when --dry-run flag is specified. See also "nzn help scripts".
+
+			  If --root flag is specified, the working copy is deployed under <dir> like
+			  DESTDIR. The repository is copied into <dir>, and links point to the copy.
+			  Absolute links point to the paths without <dir>. The state of the deployed
+			  working copy is recorded in the copy, and hooks and scripts are not run.
 		`)),
 		Flags:  flags,
 		Action: update,
@@ -36,32 +77,130 @@ func init() {
 
 func update(ctx *cli.Context) error {
 	repo := ctx.Data.(*nazuna.Repository)
+	dry := ctx.Bool("dry-run")
+	deploy := ctx.String("root") != ""
+	if deploy {
+		var err error
+		if repo, err = repo.Deploy(ctx.String("root")); err != nil {
+			return err
+		}
+		if !dry {
+			if err := repo.Snapshot(); err != nil {
+				return err
+			}
+		}
+	}
 	wc, err := repo.WC()
 	if err != nil {
 		return err
 	}
+	if !dry && !deploy {
+		if err := runHooks(wc, "pre-update", nil); err != nil {
+			return err
+		}
+	}
+	removed, err := unlinkWC(repo, wc, dry)
+	if err != nil {
+		return err
+	}
+	linked, failed := linkWC(repo, wc, dry)
+
+	app.Printf("%d updated, %d removed, %d failed\n", len(linked), removed, failed)
+	switch {
+	case dry && deploy:
+		return nil
+	case dry:
+		_, err := runScripts(wc, true)
+		return err
+	}
+	if err := wc.Flush(); err != nil {
+		return err
+	}
+	// hooks and scripts are not run in the alternate root
+	if !deploy {
+		n, err := postUpdate(wc, linked)
+		if err != nil {
+			return err
+		}
+		failed += n
+	}
+	if failed > 0 {
+		return SystemExit(1)
+	}
+	return nil
+}
+
+func postUpdate(wc *nazuna.WC, linked []*nazuna.Entry) (failed int, err error) {
+	paths := make(map[string][]string)
+	for _, e := range linked {
+		paths[e.Layer] = append(paths[e.Layer], e.Path)
+	}
+	if err = runHooks(wc, "post-link", paths); err != nil {
+		return
+	}
+	if failed, err = runScripts(wc, false); err != nil {
+		return
+	}
+	err = runHooks(wc, "post-update", nil)
+	return
+}
+
+func runScripts(wc *nazuna.WC, dry bool) (failed int, err error) {
+	list, err := wc.Scripts()
+	if err != nil {
+		return
+	}
+	for _, s := range list {
+		if wc.ScriptStatus(s) == "ok" {
+			continue
+		}
+		app.Printf("run %v\n", s)
+		if dry {
+			continue
+		}
+		if err := wc.RunScript(s); err != nil {
+			app.Errorln("error:", err)
+			failed++
+		}
+		if err = wc.Flush(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func runHooks(wc *nazuna.WC, event string, paths map[string][]string) error {
+	err := wc.RunHooks(event, paths)
+	var he *nazuna.HookError
+	if errors.As(err, &he) {
+		app.Errorln("nzn:", err)
+		return SystemExit(3)
+	}
+	return err
+}
+
+func unlinkWC(repo *nazuna.Repository, wc *nazuna.WC, dry bool) (removed int, err error) {
 	ul, err := wc.MergeLayers()
 	if err != nil {
-		return wc.Errorf(err)
+		return 0, wc.Errorf(err)
 	}
 
-	updated, removed, failed := 0, 0, 0
 	for _, e := range ul {
 		switch {
 		case !wc.Exists(e.Path):
 			continue
 		case !wc.IsLink(e.Path):
-			return fmt.Errorf("%v: not tracked", e.Path)
+			return removed, fmt.Errorf("%v: not tracked", e.Path)
 		}
 		app.Println(e.Format("unlink %v -/- %v"))
 		switch e.Type {
 		case "link":
 			if !wc.LinksTo(e.Path, e.Origin) {
-				return fmt.Errorf("not linked to '%v'", e.Origin)
+				return removed, fmt.Errorf("not linked to '%v'", e.Origin)
 			}
 		case "subrepo":
 			if !wc.LinksTo(e.Path, repo.SubrepoFor(e.Origin)) {
-				return fmt.Errorf("not linked to '%v'", e.Origin)
+				return removed, fmt.Errorf("not linked to '%v'", e.Origin)
 			}
 		default:
 			var origin string
@@ -71,15 +210,28 @@ func update(ctx *cli.Context) error {
 				origin = e.Path
 			}
 			if !wc.LinksTo(e.Path, repo.PathFor(nil, filepath.Join(e.Layer, origin))) {
-				return fmt.Errorf("not linked to layer '%v'", e.Layer)
+				return removed, fmt.Errorf("not linked to layer '%v'", e.Layer)
 			}
 		}
-		if err := wc.Unlink(e.Path); err != nil {
-			return err
+		// files which are hidden by unlinking
+		list, err := wc.Untracked(e)
+		if err != nil {
+			return removed, err
+		}
+		for _, p := range list {
+			app.Errorf("warning: '%v' is untracked in layer '%v'\n", p, e.Layer)
+		}
+		if !dry {
+			if err := wc.Unlink(e.Path); err != nil {
+				return removed, err
+			}
 		}
 		removed++
 	}
+	return
+}
 
+func linkWC(repo *nazuna.Repository, wc *nazuna.WC, dry bool) (linked []*nazuna.Entry, failed int) {
 	for i := 0; i < len(wc.State.WC); i++ {
 		e := wc.State.WC[i]
 		var origin string
@@ -103,23 +255,17 @@ func update(ctx *cli.Context) error {
 			continue
 		}
 		app.Println(e.Format("link %v --> %v"))
-		if err := wc.Link(origin, e.Path); err != nil {
+		if dry {
+			linked = append(linked, e)
+		} else if err := wc.Link(origin, e.Path); err != nil {
 			app.Errorln("error:", wc.Errorf(err))
 			copy(wc.State.WC[i:], wc.State.WC[i+1:])
 			wc.State.WC = wc.State.WC[:len(wc.State.WC)-1]
 			i--
 			failed++
 		} else {
-			updated++
+			linked = append(linked, e)
 		}
 	}
-
-	app.Printf("%d updated, %d removed, %d failed\n", updated, removed, failed)
-	if err := wc.Flush(); err != nil {
-		return err
-	}
-	if failed > 0 {
-		return SystemExit(1)
-	}
-	return nil
+	return
 }
@@ -1,7 +1,7 @@
 //
 // nazuna/cmd/nzn :: update_test.go
 //
-//   Copyright (c) 2013-2022 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -174,6 +174,281 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestUpdateDryRun(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.gitconfig"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.vimrc"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "add", "."},
+		},
+		{
+			cmd: []string{"nzn", "update", "-n"},
+			out: cli.Dedent(`
+				link .gitconfig --> a
+				link .vimrc --> a
+				2 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"ls", "."},
+			out: cli.Dedent(`
+				.nzn/
+			`),
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				link .gitconfig --> a
+				link .vimrc --> a
+				2 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "b"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/b/.vimrc"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "add", "."},
+		},
+		{
+			cmd: []string{"nzn", "update", "--dry-run"},
+			out: cli.Dedent(`
+				unlink .vimrc -/- a
+				link .vimrc --> b
+				1 updated, 1 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				unlink .vimrc -/- a
+				link .vimrc --> b
+				1 updated, 1 removed, 0 failed
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateFolding(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"mkdir", ".nzn/r/a/.vim"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.vim/vimrc"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "add", "."},
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				link .vim/ --> a
+				1 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"touch", ".vim/viminfo"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "b"},
+		},
+		{
+			cmd: []string{"mkdir", ".nzn/r/b/.vim"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/b/.vim/gvimrc"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "add", "nazuna.json", "b"},
+		},
+		// unfold
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				unlink .vim/ -/- a
+				warning: '.vim/viminfo' is untracked in layer 'a'
+				link .vim/gvimrc --> b
+				link .vim/vimrc --> a
+				2 updated, 1 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"ls", ".vim"},
+			out: cli.Dedent(`
+				gvimrc
+				vimrc
+			`),
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				0 updated, 0 removed, 0 failed
+			`),
+		},
+		// untracked file in the directory
+		{
+			cmd: []string{"touch", ".vim/local.vim"},
+		},
+		{
+			cmd: []string{"nzn", "alias", "-l", "b", ".vim/gvimrc", ".gvimrc"},
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				unlink .vim/gvimrc -/- b
+				link .gvimrc --> b:.vim/gvimrc
+				1 updated, 1 removed, 0 failed
+			`),
+		},
+		// fold
+		{
+			cmd: []string{"rm", ".vim/local.vim"},
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				unlink .vim/vimrc -/- a
+				link .vim/ --> a
+				1 updated, 1 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"ls", ".vim"},
+			out: cli.Dedent(`
+				viminfo
+				vimrc
+			`),
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				0 updated, 0 removed, 0 failed
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateRoot(t *testing.T) {
+	s := script{
+		{
+			cmd: []string{"setup"},
+		},
+		{
+			cmd: []string{"cd", "$wc"},
+		},
+		{
+			cmd: []string{"nzn", "init", "--vcs", "git"},
+		},
+		{
+			cmd: []string{"nzn", "layer", "-c", "a"},
+		},
+		{
+			cmd: []string{"touch", ".nzn/r/a/.vimrc"},
+		},
+		{
+			cmd: []string{"nzn", "vcs", "add", "."},
+		},
+		{
+			cmd: []string{"nzn", "update", "-n", "--root", "$tempdir/image"},
+			out: cli.Dedent(`
+				link .vimrc --> a
+				1 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"ls", "$tempdir"},
+			out: cli.Dedent(`
+				home/
+				public/
+				wc/
+			`),
+		},
+		{
+			cmd: []string{"nzn", "update", "--root", "$tempdir/image"},
+			out: cli.Dedent(`
+				link .vimrc --> a
+				1 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"nzn", "update", "--root", "$tempdir/image"},
+			out: cli.Dedent(`
+				0 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"ls", "."},
+			out: cli.Dedent(`
+				.nzn/
+			`),
+		},
+		{
+			cmd: []string{"ls", ".nzn"},
+			out: cli.Dedent(`
+				config.json
+				r/
+			`),
+		},
+		{
+			cmd: []string{"nzn", "update"},
+			out: cli.Dedent(`
+				link .vimrc --> a
+				1 updated, 0 removed, 0 failed
+			`),
+		},
+		{
+			cmd: []string{"nzn", "vcs", "rm", "-q", "-f", "a/.vimrc"},
+		},
+		{
+			cmd: []string{"nzn", "update", "--root", "$tempdir/image"},
+			out: cli.Dedent(`
+				unlink .vimrc -/- a
+				0 updated, 1 removed, 0 failed
+			`),
+		},
+	}
+	if err := s.exec(t); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestUpdateError(t *testing.T) {
 	s := script{
 		{
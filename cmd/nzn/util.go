@@ -1,7 +1,7 @@
 //
 // nazuna/cmd/nzn :: util.go
 //
-//   Copyright (c) 2013-2020 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -9,10 +9,16 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"io/fs"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/hattya/go.cli"
+	"github.com/hattya/nazuna"
 )
 
 type UI struct {
@@ -28,7 +34,64 @@ func (ui *UI) Exec(cmd *exec.Cmd) (err error) {
 	cmd.Stdout = ui.Stdout
 	cmd.Stderr = ui.Stderr
 	if err = cmd.Run(); err != nil {
-		err = fmt.Errorf("%v: %v", cmd.Args[0], err)
+		err = fmt.Errorf("%v: %w", cmd.Args[0], err)
 	}
 	return err
 }
+
+func confirm(prompt string) bool {
+	app.Printf("%v [y/N] ", prompt)
+	s, _ := bufio.NewReader(app.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// relPath returns the path relative to the working copy. The path is relative
+// to the current directory, or to the working copy when the current directory
+// is outside of it.
+func relPath(wc *nazuna.WC, path string) (string, error) {
+	base := '/'
+	if wd, err := os.Getwd(); err == nil {
+		rel, err := filepath.Rel(wc.PathFor("/"), wd)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			base = '.'
+		}
+	}
+	return wc.Rel(base, path)
+}
+
+func du(path string) (n int64, err error) {
+	err = filepath.WalkDir(path, func(_ string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if de.Type().IsRegular() {
+			fi, err := de.Info()
+			if err != nil {
+				return err
+			}
+			n += fi.Size()
+		}
+		return nil
+	})
+	return
+}
+
+func formatSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	v := float64(n) / 1024
+	u := "KiB"
+	for _, s := range []string{"MiB", "GiB"} {
+		if v < 1024 {
+			break
+		}
+		v /= 1024
+		u = s
+	}
+	return fmt.Sprintf("%.1f %v", v, u)
+}
@@ -0,0 +1,32 @@
+//
+// nazuna/cmd/nzn :: util_test.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package main
+
+import "testing"
+
+var formatSizeTests = []struct {
+	n int64
+	s string
+}{
+	{0, "0 B"},
+	{1023, "1023 B"},
+	{1024, "1.0 KiB"},
+	{1536, "1.5 KiB"},
+	{1 << 20, "1.0 MiB"},
+	{1 << 30, "1.0 GiB"},
+	{1 << 40, "1024.0 GiB"},
+}
+
+func TestFormatSize(t *testing.T) {
+	for _, tt := range formatSizeTests {
+		if g, e := formatSize(tt.n), tt.s; g != e {
+			t.Errorf("formatSize(%v) = %q, expected %q", tt.n, g, e)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+//
+// nazuna :: cmdserver.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package nazuna
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+var cmdServer = false
+
+func CommandServer(b bool) bool {
+	old := cmdServer
+	cmdServer = b
+	return old
+}
+
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+func exitCode(err error) int {
+	var e *exec.ExitError
+	var c exitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &e):
+		return e.ExitCode()
+	case errors.As(err, &c):
+		return int(c)
+	}
+	return -1
+}
+
+// sendError is returned when the command is not sent to the command server
+type sendError struct {
+	error
+}
+
+func (e sendError) Unwrap() error {
+	return e.error
+}
+
+type hgServer struct {
+	mu  sync.Mutex
+	cmd *exec.Cmd
+	w   io.WriteCloser
+	r   *bufio.Reader
+}
+
+func startHgServer(name, dir string) (*hgServer, error) {
+	cmd := exec.Command(name, "serve", "--cmdserver", "pipe", "--config", "ui.interactive=False")
+	cmd.Dir = dir
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	s := &hgServer{
+		cmd: cmd,
+		w:   w,
+		r:   bufio.NewReader(r),
+	}
+	// hello message
+	ch, data, err := s.read()
+	if err == nil && ch != 'o' {
+		err = fmt.Errorf("unexpected channel '%c'", ch)
+	}
+	if err == nil {
+		err = errors.New("runcommand is not supported")
+		for _, l := range strings.Split(string(data), "\n") {
+			if k, v, ok := strings.Cut(l, ": "); ok && k == "capabilities" {
+				for _, c := range strings.Fields(v) {
+					if c == "runcommand" {
+						err = nil
+					}
+				}
+			}
+		}
+	}
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("%v: cmdserver: %v", name, err)
+	}
+	return s, nil
+}
+
+func (s *hgServer) Run(stdout, stderr io.Writer, args ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := strings.Join(args, "\x00")
+	b := make([]byte, 0, 11+4+len(data))
+	b = append(b, "runcommand\n"...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+	b = append(b, data...)
+	if _, err := s.w.Write(b); err != nil {
+		return 0, sendError{err}
+	}
+	for {
+		ch, data, err := s.read()
+		if err != nil {
+			return 0, err
+		}
+		switch ch {
+		case 'o':
+			stdout.Write(data)
+		case 'e':
+			stderr.Write(data)
+		case 'r':
+			if len(data) != 4 {
+				return 0, errors.New("invalid result")
+			}
+			return int(int32(binary.BigEndian.Uint32(data))), nil
+		case 'I', 'L':
+			// no input
+			if _, err := s.w.Write(make([]byte, 4)); err != nil {
+				return 0, err
+			}
+		default:
+			if 'A' <= ch && ch <= 'Z' {
+				return 0, fmt.Errorf("unexpected channel '%c'", ch)
+			}
+		}
+	}
+}
+
+func (s *hgServer) read() (byte, []byte, error) {
+	var h [5]byte
+	if _, err := io.ReadFull(s.r, h[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(h[1:])
+	switch h[0] {
+	case 'I', 'L':
+		// length is the size of the requested input
+		return h[0], nil, nil
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(s.r, data); err != nil {
+		return 0, nil, err
+	}
+	return h[0], data, nil
+}
+
+func (s *hgServer) Close() error {
+	s.w.Close()
+	return s.cmd.Wait()
+}
@@ -0,0 +1,195 @@
+//
+// nazuna :: cmdserver_test.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package nazuna_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hattya/nazuna"
+)
+
+func TestCommandServer(t *testing.T) {
+	sandbox(t)
+	mock(t, "hg")
+	defer nazuna.CommandServer(nazuna.CommandServer(true))
+
+	if err := mkdir(".hg"); err != nil {
+		t.Fatal(err)
+	}
+	ui := new(testUI)
+	vcs, err := nazuna.FindVCS(ui, "hg", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hg := vcs.(*nazuna.Mercurial)
+	rev, err := hg.Revision()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(rev, "server ") {
+		t.Fatalf("unexpected revision: %v", rev)
+	}
+	// long-lived
+	if g, err := hg.Revision(); err != nil {
+		t.Error(err)
+	} else if e := rev; g != e {
+		t.Errorf("expected %v, got %v", e, g)
+	}
+	list, err := hg.Files(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := list, []string{"dir/file", "file"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	if _, err := hg.Output("fail"); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := hg.Status(); err == nil {
+		t.Error("expected error")
+	}
+	// interactive commands are not run by the command server
+	if err := hg.Exec("log"); err != nil {
+		t.Error(err)
+	} else if g := ui.String(); !strings.HasPrefix(g, "exec ") {
+		t.Errorf("unexpected output: %v", g)
+	}
+	// no outgoing changes
+	if err := hg.Push(); err != nil {
+		t.Error(err)
+	}
+	// shutdown
+	if err := hg.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := hg.Close(); err != nil {
+		t.Error(err)
+	}
+	switch g, err := hg.Revision(); {
+	case err != nil:
+		t.Error(err)
+	case !strings.HasPrefix(g, "server "):
+		t.Errorf("unexpected revision: %v", g)
+	case g == rev:
+		t.Error("expected new command server")
+	}
+	// fall back to exec without running the command again
+	if _, err := hg.Output("crash"); err == nil {
+		t.Error("expected error")
+	}
+	if g, err := hg.Revision(); err != nil {
+		t.Error(err)
+	} else if !strings.HasPrefix(g, "exec ") {
+		t.Errorf("unexpected revision: %v", g)
+	}
+	if err := hg.Close(); err != nil {
+		t.Error(err)
+	}
+
+	// unavailable
+	t.Setenv("MOCK_HG_CMDSERVER", "0")
+	vcs, err = nazuna.FindVCS(ui, "hg", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, err := vcs.Revision(); err != nil {
+		t.Error(err)
+	} else if !strings.HasPrefix(g, "exec ") {
+		t.Errorf("unexpected revision: %v", g)
+	}
+	// disabled
+	t.Setenv("MOCK_HG_CMDSERVER", "")
+	nazuna.CommandServer(false)
+	vcs, err = nazuna.FindVCS(ui, "hg", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, err := vcs.Revision(); err != nil {
+		t.Error(err)
+	} else if !strings.HasPrefix(g, "exec ") {
+		t.Errorf("unexpected revision: %v", g)
+	}
+}
+
+func mockHg() int {
+	args := os.Args[1:]
+	if len(args) > 2 && args[0] == "serve" && args[1] == "--cmdserver" {
+		if os.Getenv("MOCK_HG_CMDSERVER") == "0" {
+			fmt.Fprintln(os.Stderr, "hg: unknown command 'serve'")
+			return 255
+		}
+		return mockHgServe()
+	}
+	return mockHgRun("exec", os.Stdout, os.Stderr, args)
+}
+
+func mockHgServe() int {
+	r := bufio.NewReader(os.Stdin)
+	write := func(ch byte, data []byte) {
+		b := binary.BigEndian.AppendUint32([]byte{ch}, uint32(len(data)))
+		os.Stdout.Write(append(b, data...))
+	}
+	write('o', []byte(fmt.Sprintf("capabilities: getencoding runcommand\nencoding: UTF-8\npid: %v", os.Getpid())))
+	for {
+		switch l, err := r.ReadString('\n'); {
+		case err == io.EOF:
+			return 0
+		case err != nil || l != "runcommand\n":
+			return 255
+		}
+		var n uint32
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return 255
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return 255
+		}
+		args := strings.Split(string(data), "\x00")
+		if args[0] == "crash" {
+			return 255
+		}
+		var stdout, stderr bytes.Buffer
+		rc := mockHgRun("server", &stdout, &stderr, args)
+		if stdout.Len() > 0 {
+			write('o', stdout.Bytes())
+		}
+		if stderr.Len() > 0 {
+			write('e', stderr.Bytes())
+		}
+		write('r', binary.BigEndian.AppendUint32(nil, uint32(rc)))
+	}
+}
+
+func mockHgRun(mode string, stdout, stderr io.Writer, args []string) int {
+	switch args[0] {
+	case "log":
+		fmt.Fprintf(stdout, "%v %v\n", mode, os.Getpid())
+	case "status":
+		if len(args) > 1 && args[1] == "-madcn" {
+			fmt.Fprint(stdout, "dir/file\nfile\n")
+			break
+		}
+		fallthrough
+	case "fail":
+		fmt.Fprintln(stderr, "abort: fail")
+		return 255
+	case "push":
+		return 1
+	}
+	return 0
+}
@@ -1,7 +1,7 @@
 //
 // nazuna :: export_test.go
 //
-//   Copyright (c) 2014-2020 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2014-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -12,8 +12,18 @@ var (
 	SortKeys  = sortKeys
 	Marshal   = marshal
 	Unmarshal = unmarshal
+	ReadIndex = readIndex
+	ListFiles = listFiles
+
+	CreateLinkStyle = createLink
 )
 
+func NativeIndex(b bool) bool {
+	old := nativeIndex
+	nativeIndex = b
+	return old
+}
+
 func (l *Layer) SetAbst(abst *Layer) {
 	l.abst = abst
 }
@@ -22,6 +32,10 @@ func (l *Layer) SetRepo(repo *Repository) {
 	l.repo = repo
 }
 
+func (v *Mercurial) Output(args ...string) (string, error) {
+	return v.output(args...)
+}
+
 func (repo *Repository) Root() string {
 	return repo.root
 }
@@ -0,0 +1,187 @@
+//
+// nazuna :: gitindex.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package nazuna
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var errIndex = errors.New("unsupported git index")
+
+// read .git/index in-process instead of git ls-files
+var nativeIndex = true
+
+func readIndex(dir string, paths ...string) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	gitdir := filepath.Join(dir, ".git")
+	if !IsDir(gitdir) {
+		return nil, errIndex
+	}
+	// pathspecs
+	var specs []string
+	for _, p := range paths {
+		if strings.ContainsAny(p, `*?[\`) || strings.HasPrefix(p, ":") {
+			return nil, errIndex
+		}
+		if filepath.IsAbs(p) {
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return nil, errIndex
+			}
+			p = rel
+		}
+		p = filepath.ToSlash(filepath.Clean(p))
+		switch {
+		case p == ".":
+			p = ""
+		case p == ".." || strings.HasPrefix(p, "../"):
+			return nil, errIndex
+		}
+		specs = append(specs, p)
+	}
+
+	hashsz, err := hashSize(gitdir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(gitdir, "index"))
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case len(data) < 12+hashsz || string(data[:4]) != "DIRC":
+		return nil, errIndex
+	}
+	ver := binary.BigEndian.Uint32(data[4:])
+	if ver < 2 || 4 < ver {
+		return nil, errIndex
+	}
+	n := binary.BigEndian.Uint32(data[8:])
+	end := len(data) - hashsz
+	// ctime, mtime, dev, ino, mode, uid, gid, size, hash, flags
+	fixed := 40 + hashsz + 2
+	var list []string
+	var name []byte
+	off := 12
+	for i := uint32(0); i < n; i++ {
+		if end < off+fixed {
+			return nil, errIndex
+		}
+		e := data[off:]
+		mode := binary.BigEndian.Uint32(e[24:])
+		flags := binary.BigEndian.Uint16(e[40+hashsz:])
+		p := off + fixed
+		if flags&0x4000 != 0 {
+			if ver < 3 {
+				return nil, errIndex
+			}
+			p += 2
+		}
+		if ver == 4 {
+			// prefix compression
+			strip, m := varint(data[p:end])
+			if m == 0 || len(name) < strip {
+				return nil, errIndex
+			}
+			p += m
+			j := bytes.IndexByte(data[p:end], 0)
+			if j < 0 {
+				return nil, errIndex
+			}
+			name = append(name[:len(name)-strip], data[p:p+j]...)
+			off = p + j + 1
+		} else {
+			j := bytes.IndexByte(data[p:end], 0)
+			if j < 0 {
+				return nil, errIndex
+			}
+			name = append(name[:0], data[p:p+j]...)
+			off += (p - off + j + 8) &^ 7
+		}
+		// sparse directory
+		if mode&0o170000 == 0o040000 {
+			return nil, errIndex
+		}
+		if s := string(name); match(specs, s) {
+			list = append(list, s)
+		}
+	}
+	// extensions
+	for off+8 <= end {
+		sig := string(data[off : off+4])
+		size := int(binary.BigEndian.Uint32(data[off+4:]))
+		switch sig {
+		case "link", "sdir":
+			return nil, errIndex
+		}
+		off += 8 + size
+	}
+	return list, nil
+}
+
+func match(specs []string, name string) bool {
+	if specs == nil {
+		return true
+	}
+	for _, s := range specs {
+		if s == "" || s == name || strings.HasPrefix(name, s+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func varint(b []byte) (int, int) {
+	var v int
+	for i, c := range b {
+		if i > 0 {
+			v = (v + 1) << 7
+		}
+		v |= int(c & 0x7f)
+		if c&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+func hashSize(gitdir string) (int, error) {
+	f, err := os.Open(filepath.Join(gitdir, "config"))
+	switch {
+	case os.IsNotExist(err):
+		return 20, nil
+	case err != nil:
+		return 0, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		k, v, ok := strings.Cut(s.Text(), "=")
+		if ok && strings.EqualFold(strings.TrimSpace(k), "objectformat") {
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "sha1":
+				return 20, nil
+			case "sha256":
+				return 32, nil
+			}
+			return 0, errIndex
+		}
+	}
+	return 20, s.Err()
+}
@@ -0,0 +1,130 @@
+//
+// nazuna :: gitindex_test.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package nazuna_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hattya/nazuna"
+)
+
+func TestGitIndex(t *testing.T) {
+	dir := sandbox(t)
+	t.Setenv("HOME", dir)
+	git(t, "config", "--global", "user.name", "Nazuna")
+	git(t, "config", "--global", "user.email", "nazuna@example.com")
+	git(t, "init", "-q", "repo")
+	if err := os.Chdir("repo"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{
+		"a/.vimrc",
+		"a/.vim/syntax/go.vim",
+		"a/.vim/syntax/vim.vim",
+		"a b/file",
+		"ab/file",
+		"b/1/.gitconfig",
+		"b/2/.gitconfig",
+		"nazuna.json",
+		"なずな/file",
+		"long/" + fmt.Sprintf("%0200d", 0),
+	} {
+		if err := mkdir(filepath.Dir(p)); err != nil {
+			t.Fatal(err)
+		}
+		if err := touch(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git(t, "add", ".")
+	git(t, "commit", "-qm", ".")
+	if err := touch("new"); err != nil {
+		t.Fatal(err)
+	}
+	// extended flags
+	git(t, "add", "-N", "new")
+	if err := os.Remove(filepath.Join("b", "2", ".gitconfig")); err != nil {
+		t.Fatal(err)
+	}
+	// unmerged entries
+	git(t, "checkout", "-qb", "topic")
+	if err := os.WriteFile("nazuna.json", []byte("topic\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	git(t, "commit", "-qam", "topic")
+	git(t, "checkout", "-q", "-")
+	if err := os.WriteFile("nazuna.json", []byte("master\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	git(t, "commit", "-qam", "master")
+	if err := exec.Command("git", "merge", "-q", "topic").Run(); err == nil {
+		t.Fatal("expected conflict")
+	}
+
+	abs, err := filepath.Abs("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vcs, err := nazuna.VCSFor(new(testUI), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"2", "3", "4"} {
+		git(t, "update-index", "--index-version", v)
+		for _, paths := range [][]string{
+			{"."},
+			{"a"},
+			{"a", "b/1"},
+			{"a b"},
+			{abs},
+			{"nazuna.json"},
+			{"b/2/"},
+			{"_"},
+			{"a*"},
+		} {
+			nazuna.NativeIndex(false)
+			e, err := nazuna.ListFiles(vcs, paths...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nazuna.NativeIndex(true)
+			g, err := nazuna.ListFiles(vcs, paths...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (len(g) != 0 || len(e) != 0) && !reflect.DeepEqual(g, e) {
+				t.Errorf("index version %v: List(%q) = %q, expected %q", v, paths, g, e)
+			}
+			// fallback
+			switch _, err := nazuna.ReadIndex(".", paths...); {
+			case paths[0] == "a*":
+				if err == nil {
+					t.Error("expected error")
+				}
+			case err != nil:
+				t.Errorf("index version %v: %v", v, err)
+			}
+		}
+	}
+	// split index
+	git(t, "update-index", "--split-index")
+	if _, err := nazuna.ReadIndex("."); err == nil {
+		t.Error("expected error")
+	}
+	if list, err := nazuna.ListFiles(vcs, "a"); err != nil {
+		t.Error(err)
+	} else if g, e := len(list), 3; g != e {
+		t.Errorf("len(ListFiles()) = %v, expected %v", g, e)
+	}
+}
@@ -0,0 +1,81 @@
+//
+// nazuna :: http.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package nazuna
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// timeout of the clients which do not have it
+const defaultTimeout = 30 * time.Second
+
+type HTTPClient struct {
+	Client  *http.Client
+	Tokens  map[string]string
+	Retries int
+	Backoff time.Duration
+}
+
+func (c *HTTPClient) Get(uri string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	// never send tokens in plain text
+	if tok, ok := c.Tokens[req.URL.Hostname()]; ok && req.URL.Scheme == "https" {
+		req.Header.Set("Authorization", "Bearer "+tok)
+	}
+	backoff := c.Backoff
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+	for i := 0; ; i++ {
+		data, retry, err := c.do(req)
+		if err == nil || !retry || i >= c.Retries {
+			return data, err
+		}
+		time.Sleep(backoff << i)
+	}
+}
+
+func (c *HTTPClient) do(req *http.Request) ([]byte, bool, error) {
+	cli := c.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	if cli.Timeout <= 0 {
+		v := *cli
+		v.Timeout = defaultTimeout
+		cli = &v
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return nil, retry, fmt.Errorf("%v: %v", req.URL, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, err
+	}
+	return data, false, nil
+}
+
+func httpGet(c *HTTPClient, uri string) ([]byte, error) {
+	if c == nil {
+		c = new(HTTPClient)
+	}
+	return c.Get(uri)
+}
@@ -0,0 +1,115 @@
+//
+// nazuna :: http_test.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package nazuna_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattya/nazuna"
+	"github.com/hattya/nazuna/internal/test"
+)
+
+func TestHTTPClient(t *testing.T) {
+	var n int
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		switch r.URL.Path {
+		case "/token":
+			fmt.Fprint(w, r.Header.Get("Authorization"))
+		case "/retry":
+			if n < 3 {
+				http.Error(w, "", http.StatusServiceUnavailable)
+			} else {
+				fmt.Fprint(w, n)
+			}
+		case "/busy":
+			http.Error(w, "", http.StatusTooManyRequests)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	c := &nazuna.HTTPClient{
+		Client: test.NewHTTPClient(s.Listener.Addr().String()),
+		Tokens: map[string]string{
+			"example.com": "secret",
+		},
+		Retries: 2,
+		Backoff: time.Millisecond,
+	}
+	for _, tt := range []struct {
+		uri, body string
+	}{
+		{"https://example.com/token", "Bearer secret"},
+		{"https://example.org/token", ""},
+	} {
+		data, err := c.Get(tt.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g, e := string(data), tt.body; g != e {
+			t.Errorf("HTTPClient.Get(%q) = %q, expected %q", tt.uri, g, e)
+		}
+	}
+	// retry
+	n = 0
+	data, err := c.Get("https://example.com/retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := string(data), "3"; g != e {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	n = 0
+	switch _, err := c.Get("https://example.com/busy"); {
+	case err == nil:
+		t.Error("expected error")
+	case !strings.HasSuffix(err.Error(), "/busy: 429 Too Many Requests"):
+		t.Error("unexpected error:", err)
+	}
+	if g, e := n, 3; g != e {
+		t.Errorf("expected %v requests, got %v", e, g)
+	}
+	// no retry
+	n = 0
+	if _, err := c.Get("https://example.com/_"); err == nil {
+		t.Error("expected error")
+	}
+	if g, e := n, 1; g != e {
+		t.Errorf("expected %v requests, got %v", e, g)
+	}
+}
+
+func TestRepositoryHTTPClient(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"scm":"%v"}`, r.Header.Get("Authorization")[7:])
+	}))
+	defer s.Close()
+
+	repo := init_(t)
+	repo.HTTPClient = &nazuna.HTTPClient{
+		Client: test.NewHTTPClient(s.Listener.Addr().String()),
+		Tokens: map[string]string{
+			"api.bitbucket.org": "hg",
+		},
+	}
+	r, err := repo.NewRemote("bitbucket.org/nazuna/token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := r.VCS, "hg"; g != e {
+		t.Errorf("Remote.VCS = %v, expected %v", g, e)
+	}
+}
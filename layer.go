@@ -1,7 +1,7 @@
 //
 // nazuna :: layer.go
 //
-//   Copyright (c) 2013-2020 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -10,16 +10,21 @@ package nazuna
 
 import (
 	"fmt"
+	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 )
 
 type Layer struct {
-	Name     string                `json:"name"`
-	Layers   []*Layer              `json:"layers,omitempty"`
-	Aliases  map[string]string     `json:"aliases,omitempty"`
-	Links    map[string][]*Link    `json:"links,omitempty"`
-	Subrepos map[string][]*Subrepo `json:"subrepos,omitempty"`
+	Name      string                `json:"name"`
+	Layers    []*Layer              `json:"layers,omitempty"`
+	Aliases   map[string]string     `json:"aliases,omitempty"`
+	Links     map[string][]*Link    `json:"links,omitempty"`
+	Subrepos  map[string][]*Subrepo `json:"subrepos,omitempty"`
+	Folding   map[string]string     `json:"folding,omitempty"`
+	LinkStyle map[string]string     `json:"linkstyle,omitempty"`
+	Hooks     map[string][]string   `json:"hooks,omitempty"`
 
 	repo *Repository
 	abst *Layer
@@ -78,7 +83,7 @@ func (l *Layer) NewSubrepo(src, dst string) (*Subrepo, error) {
 	}
 
 	dir, name := SplitPath(filepath.ToSlash(filepath.Clean(dst)))
-	if name == filepath.Base(src) {
+	if name == SubrepoName(src) {
 		name = ""
 	}
 	sub := &Subrepo{
@@ -93,6 +98,80 @@ func (l *Layer) NewSubrepo(src, dst string) (*Subrepo, error) {
 	return sub, nil
 }
 
+func (l *Layer) RemoveSubrepo(dst string) (*Subrepo, error) {
+	dir, name := SplitPath(filepath.ToSlash(filepath.Clean(dst)))
+	for i, sub := range l.Subrepos[dir] {
+		if sub.Name == name || (sub.Name == "" && SubrepoName(sub.Src) == name) {
+			l.Subrepos[dir] = append(l.Subrepos[dir][:i], l.Subrepos[dir][i+1:]...)
+			if len(l.Subrepos[dir]) == 0 {
+				delete(l.Subrepos, dir)
+			}
+			return sub, nil
+		}
+	}
+	return nil, fmt.Errorf("subrepo '%v' does not exist!", dst)
+}
+
+func (l *Layer) SubrepoOf(src string) *Subrepo {
+	for _, dir := range sortKeys(l.Subrepos) {
+		for _, sub := range l.Subrepos[dir] {
+			if sub.Src == src {
+				return sub
+			}
+		}
+	}
+	return nil
+}
+
+func (l *Layer) Folds(p string) (bool, error) {
+	switch v, err := l.lookup(p, "folding", func(l *Layer) map[string]string { return l.Folding }); {
+	case err != nil:
+		return false, err
+	case v == "" || v == "fold":
+		return true, nil
+	case v == "no-fold":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown folding policy '%v'", v)
+	}
+}
+
+func (l *Layer) LinkStyleOf(p string) (string, error) {
+	v, err := l.lookup(p, "link style", func(l *Layer) map[string]string { return l.LinkStyle })
+	if err != nil {
+		return "", err
+	}
+	if err := checkLinkStyle(v); err != nil {
+		return "", err
+	}
+	return v, nil
+}
+
+func (l *Layer) lookup(p, name string, get func(*Layer) map[string]string) (string, error) {
+	list := []map[string]string{get(l)}
+	if l.abst != nil {
+		list = append(list, get(l.abst))
+	}
+	// the longest pattern which matches the nearest path
+	for ; p != "." && p != "/"; p = path.Dir(p) {
+		for _, m := range list {
+			var k string
+			for _, pat := range sortKeys(m) {
+				switch ok, err := path.Match(pat, p); {
+				case err != nil:
+					return "", fmt.Errorf("invalid %v pattern '%v'", name, pat)
+				case ok && len(pat) > len(k):
+					k = pat
+				}
+			}
+			if k != "" {
+				return m[k], nil
+			}
+		}
+	}
+	return "", nil
+}
+
 func (l *Layer) check(path string, dir bool) error {
 	if len(l.Layers) != 0 {
 		return fmt.Errorf("layer '%v' is abstract", l.Path())
@@ -110,6 +189,14 @@ func (l *Layer) check(path string, dir bool) error {
 	return nil
 }
 
+func checkLinkStyle(s string) error {
+	switch s {
+	case "", "relative", "absolute", "hard":
+		return nil
+	}
+	return fmt.Errorf("unknown link style '%v'", s)
+}
+
 type Link struct {
 	Path []string `json:"path,omitempty"`
 	Src  string   `json:"src"`
@@ -117,6 +204,13 @@ type Link struct {
 }
 
 type Subrepo struct {
-	Src  string `json:"src"`
-	Name string `json:"name,omitempty"`
+	Src   string `json:"src"`
+	Name  string `json:"name,omitempty"`
+	Rev   string `json:"rev,omitempty"`
+	VCS   string `json:"vcs,omitempty"`
+	Build string `json:"build,omitempty"`
+}
+
+func SubrepoName(src string) string {
+	return strings.TrimSuffix(filepath.Base(src), ".git")
 }
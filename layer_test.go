@@ -1,7 +1,7 @@
 //
 // nazuna :: layer_test.go
 //
-//   Copyright (c) 2014-2022 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2014-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -175,8 +175,8 @@ func TestNewSubrepo(t *testing.T) {
 	}
 
 	subrepos[""] = []*nazuna.Subrepo{
-		{"a", "z"},
-		{src, "dst"},
+		{Src: "a", Name: "z"},
+		{Src: src, Name: "dst"},
 	}
 	if _, err := l.NewSubrepo(src, "dst"); err != nil {
 		t.Fatal(err)
@@ -194,8 +194,8 @@ func TestNewSubrepo(t *testing.T) {
 
 	l.Subrepos = nil
 	subrepos[""] = []*nazuna.Subrepo{
-		{"a", "z"},
-		{src, ""},
+		{Src: "a", Name: "z"},
+		{Src: src},
 	}
 	if _, err := l.NewSubrepo(src, filepath.Base(src)); err != nil {
 		t.Fatal(err)
@@ -258,6 +258,130 @@ func TestNewSubrepoError(t *testing.T) {
 	}
 }
 
+func TestSubrepoName(t *testing.T) {
+	for _, tt := range []struct {
+		src, name string
+	}{
+		{"github.com/hattya/nazuna", "nazuna"},
+		{"https://example.com/repo.git", "repo"},
+		{"git@example.com:user/repo.git", "repo"},
+		{"file:///srv/repo/", "repo"},
+	} {
+		if g, e := nazuna.SubrepoName(tt.src), tt.name; g != e {
+			t.Errorf("SubrepoName(%q) = %q, expected %q", tt.src, g, e)
+		}
+	}
+}
+
+func TestRemoveSubrepo(t *testing.T) {
+	repo := initLayer(t)
+
+	l, err := repo.LayerOf("abst/layer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.NewSubrepo("github.com/hattya/nazuna", filepath.Join("dir", "nazuna")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.NewSubrepo("github.com/hattya/go.cli", filepath.Join("dir", "cli")); err != nil {
+		t.Fatal(err)
+	}
+	subrepos := map[string][]*nazuna.Subrepo{
+		"dir": {
+			{Src: "github.com/hattya/go.cli", Name: "cli"},
+		},
+	}
+	if sub, err := l.RemoveSubrepo(filepath.Join("dir", "nazuna")); err != nil {
+		t.Fatal(err)
+	} else if g, e := sub.Src, "github.com/hattya/nazuna"; g != e {
+		t.Errorf("expected %v, got %v", e, g)
+	}
+	if !reflect.DeepEqual(l.Subrepos, subrepos) {
+		t.Error("unexpected result")
+	}
+	if _, err := l.RemoveSubrepo(filepath.Join("dir", "nazuna")); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := l.RemoveSubrepo(filepath.Join("dir", "cli")); err != nil {
+		t.Fatal(err)
+	}
+	if g, e := len(l.Subrepos), 0; g != e {
+		t.Errorf("expected %v, got %v", e, g)
+	}
+}
+
+func TestSubrepoOf(t *testing.T) {
+	repo := initLayer(t)
+
+	l, err := repo.LayerOf("abst/layer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := l.NewSubrepo("github.com/hattya/nazuna", "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.Rev = "master"
+	if g, e := l.SubrepoOf("github.com/hattya/nazuna"), sub; g != e {
+		t.Errorf("expected %v, got %v", e, g)
+	}
+	if g := l.SubrepoOf("github.com/hattya/go.cli"); g != nil {
+		t.Errorf("expected nil, got %v", g)
+	}
+}
+
+func TestFolds(t *testing.T) {
+	repo := initLayer(t)
+
+	abst, err := repo.LayerOf("abst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := repo.LayerOf("abst/layer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	abst.Folding = map[string]string{
+		"*":    "no-fold",
+		".vim": "fold",
+	}
+	l.Folding = map[string]string{
+		".config/*":   "fold",
+		".config/foo": "no-fold",
+	}
+	for _, tt := range []struct {
+		path string
+		fold bool
+	}{
+		{".config", false},
+		{".config/bar", true},
+		{".config/foo", false},
+		{".config/foo/bar", false},
+		{".vim", true},
+		{".vim/autoload", true},
+	} {
+		switch g, err := l.Folds(tt.path); {
+		case err != nil:
+			t.Error(err)
+		case g != tt.fold:
+			t.Errorf("Layer.Folds(%q) = %v, expected %v", tt.path, g, tt.fold)
+		}
+	}
+
+	l.Folding = map[string]string{
+		".config": "_",
+	}
+	if _, err := l.Folds(".config/foo"); err == nil {
+		t.Error("expected error")
+	}
+	l.Folding = map[string]string{
+		"[": "fold",
+	}
+	if _, err := l.Folds(".config"); err == nil {
+		t.Error("expected error")
+	}
+}
+
 func initLayer(t *testing.T) *nazuna.Repository {
 	t.Helper()
 
@@ -1,7 +1,7 @@
 //
 // nazuna :: nazuna_test.go
 //
-//   Copyright (c) 2013-2025 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -13,6 +13,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/hattya/nazuna"
@@ -22,6 +24,17 @@ func init() {
 	nazuna.Discover(false)
 }
 
+func TestMain(m *testing.M) {
+	// run as a mock command
+	switch name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe"); {
+	case name == "hg":
+		os.Exit(mockHg())
+	case strings.HasPrefix(name, "nzn-vcs-"):
+		os.Exit(mockPlugin())
+	}
+	os.Exit(m.Run())
+}
+
 type testUI struct {
 	bytes.Buffer
 }
@@ -43,6 +56,30 @@ func mkdir(s ...string) error {
 	return os.MkdirAll(filepath.Join(s...), 0o777)
 }
 
+func mock(t *testing.T, name string) {
+	t.Helper()
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(t.TempDir(), "bin")
+	if err := mkdir(bin); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	if err := os.WriteFile(filepath.Join(bin, name), data, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
 func sandbox(t *testing.T) string {
 	t.Helper()
 
@@ -0,0 +1,159 @@
+//
+// nazuna :: none.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package nazuna
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func init() {
+	RegisterVCS("none", ".none", newNone)
+}
+
+type None struct {
+	BaseVCS
+}
+
+func newNone(ui UI, dir string) VCS {
+	return &None{BaseVCS{
+		Name: "None",
+		UI:   ui,
+		Dir:  dir,
+	}}
+}
+
+func (v *None) Exec(...string) error {
+	return errors.New("VCS.Exec not implemented")
+}
+
+func (v *None) Init(dir string) error {
+	return os.MkdirAll(filepath.Join(dir, ".none"), 0o777)
+}
+
+func (v *None) Add(...string) error {
+	return nil
+}
+
+func (v *None) Files(paths ...string) ([]string, error) {
+	ignore, err := v.ignore()
+	if err != nil {
+		return nil, err
+	}
+	base, err := filepath.Abs(v.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var list []string
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(base, p)
+		}
+		err := filepath.WalkDir(p, func(p string, de fs.DirEntry, err error) error {
+			switch {
+			case os.IsNotExist(err):
+				return nil
+			case err != nil:
+				return err
+			}
+			rel, err := filepath.Rel(base, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			switch {
+			case rel == ".":
+				return nil
+			case strings.HasPrefix(rel, "../"):
+				return filepath.SkipDir
+			case ignore(rel, de.IsDir()):
+				if de.IsDir() {
+					return filepath.SkipDir
+				}
+			case !de.IsDir():
+				list = append(list, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+func (v *None) ignore() (func(string, bool) bool, error) {
+	pats := []string{"/.none/", ".git/", ".hg/"}
+	f, err := os.Open(filepath.Join(v.Dir, ".nznignore"))
+	switch {
+	case err == nil:
+		defer f.Close()
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			if l := strings.TrimSpace(s.Text()); l != "" && l[0] != '#' {
+				pats = append(pats, l)
+			}
+		}
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+	return func(rel string, dir bool) bool {
+		for _, pat := range pats {
+			if strings.HasSuffix(pat, "/") {
+				if !dir {
+					continue
+				}
+				pat = pat[:len(pat)-1]
+			}
+			name := path.Base(rel)
+			if strings.HasPrefix(pat, "/") || strings.Contains(pat, "/") {
+				pat = strings.TrimPrefix(pat, "/")
+				name = rel
+			}
+			if ok, _ := path.Match(pat, name); ok {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func (v *None) Update() error {
+	return nil
+}
+
+func (v *None) Status() ([]*Status, error) {
+	return nil, nil
+}
+
+func (v *None) Commit(string, ...string) error {
+	return errors.New("VCS.Commit not supported")
+}
+
+func (v *None) Pull() error {
+	return nil
+}
+
+func (v *None) Push() error {
+	return errors.New("VCS.Push not supported")
+}
+
+func (v *None) Log(int) ([]*LogEntry, error) {
+	return nil, nil
+}
@@ -0,0 +1,116 @@
+//
+// nazuna :: none_test.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package nazuna_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hattya/nazuna"
+)
+
+func TestNoneVCS(t *testing.T) {
+	sandbox(t)
+
+	ui := new(testUI)
+	vcs, err := nazuna.FindVCS(ui, "none", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vcs.Init("repo"); err != nil {
+		t.Fatal(err)
+	}
+	vcs, err = nazuna.VCSFor(ui, "repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := vcs.String(), "None"; g != e {
+		t.Errorf("VCS.String() = %v, expected %v", g, e)
+	}
+
+	for _, p := range [][]string{
+		{"repo", "a", ".vimrc"},
+		{"repo", "a", ".vim", "syntax", "go.vim"},
+		{"repo", "a", ".vim", "tmp", "file"},
+		{"repo", "a", "file.swp"},
+		{"repo", "b", ".git", "config"},
+		{"repo", "b", ".vimrc"},
+		{"repo", "nazuna.json"},
+	} {
+		if err := mkdir(filepath.Join(p[:len(p)-1]...)); err != nil {
+			t.Fatal(err)
+		}
+		if err := touch(p...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := "# comment\n*.swp\n/a/.vim/tmp/\n"
+	if err := os.WriteFile(filepath.Join("repo", ".nznignore"), []byte(data), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := vcs.Add("."); err != nil {
+		t.Error(err)
+	}
+
+	list, err := nazuna.ListFiles(vcs, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := list, []string{
+		".nznignore",
+		"a/.vim/syntax/go.vim",
+		"a/.vimrc",
+		"b/.vimrc",
+		"nazuna.json",
+	}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	abs, err := filepath.Abs(filepath.Join("repo", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err = nazuna.ListFiles(vcs, abs, "_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := list, []string{
+		"a/.vim/syntax/go.vim",
+		"a/.vimrc",
+	}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+
+	if err := vcs.Exec("status"); err == nil {
+		t.Error("expected error")
+	}
+	if st, err := vcs.Status(); err != nil || st != nil {
+		t.Errorf("expected (nil, nil), got (%v, %v)", st, err)
+	}
+	for _, f := range []func() error{
+		vcs.Update,
+		vcs.Pull,
+	} {
+		if err := f(); err != nil {
+			t.Error(err)
+		}
+	}
+	for _, f := range []func() error{
+		vcs.Push,
+		func() error { return vcs.Commit(".") },
+	} {
+		if err := f(); err == nil {
+			t.Error("expected error")
+		}
+	}
+	if _, err := vcs.Log(0); err != nil {
+		t.Error(err)
+	}
+}
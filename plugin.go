@@ -0,0 +1,344 @@
+//
+// nazuna :: plugin.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package nazuna
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pluginPrefix  = "nzn-vcs-"
+	pluginVersion = 1
+)
+
+type Plugin struct {
+	BaseVCS
+
+	ctrlDir string
+}
+
+type pluginRequest struct {
+	Version int      `json:"version"`
+	Method  string   `json:"method"`
+	Args    []string `json:"args,omitempty"`
+}
+
+type pluginResponse struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Output string          `json:"output,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+type pluginInfo struct {
+	Name    string `json:"name"`
+	CtrlDir string `json:"ctrlDir"`
+}
+
+type pluginStatus struct {
+	Code string `json:"code"`
+	Path string `json:"path"`
+}
+
+type pluginLogEntry struct {
+	ID      string    `json:"id"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+}
+
+func newPlugin(ui UI, dir, path string) (*Plugin, error) {
+	v := &Plugin{BaseVCS: BaseVCS{
+		Cmd: path,
+		UI:  ui,
+	}}
+	var info pluginInfo
+	if err := v.call("probe", nil, &info); err != nil {
+		return nil, err
+	}
+	if info.CtrlDir == "" {
+		return nil, fmt.Errorf("%v: control directory is not specified", pluginName(path))
+	}
+	v.Name = info.Name
+	if v.Name == "" {
+		v.Name = strings.TrimPrefix(pluginName(path), pluginPrefix)
+	}
+	v.Dir = dir
+	v.ctrlDir = info.CtrlDir
+	return v, nil
+}
+
+func (v *Plugin) Exec(args ...string) error {
+	return v.call("exec", args, nil)
+}
+
+func (v *Plugin) Init(dir string) error {
+	return v.call("init", []string{dir}, nil)
+}
+
+func (v *Plugin) Clone(src, dst string) error {
+	return v.call("clone", []string{src, dst}, nil)
+}
+
+func (v *Plugin) Add(paths ...string) error {
+	return v.call("add", paths, nil)
+}
+
+func (v *Plugin) Files(paths ...string) ([]string, error) {
+	var list []string
+	if err := v.call("list", paths, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (v *Plugin) Update() error {
+	return v.call("update", nil, nil)
+}
+
+func (v *Plugin) Fetch() error {
+	return v.call("fetch", nil, nil)
+}
+
+func (v *Plugin) Checkout(rev string) error {
+	return v.call("checkout", []string{rev}, nil)
+}
+
+func (v *Plugin) Revision() (string, error) {
+	var rev string
+	if err := v.call("revision", nil, &rev); err != nil {
+		return "", err
+	}
+	return rev, nil
+}
+
+func (v *Plugin) Status() ([]*Status, error) {
+	var st []*pluginStatus
+	if err := v.call("status", nil, &st); err != nil {
+		return nil, err
+	}
+	var list []*Status
+	for _, s := range st {
+		if len(s.Code) != 1 {
+			return nil, fmt.Errorf("%v: invalid status code '%v'", v.Name, s.Code)
+		}
+		list = append(list, &Status{
+			Code: s.Code[0],
+			Path: s.Path,
+		})
+	}
+	return list, nil
+}
+
+func (v *Plugin) Commit(msg string, paths ...string) error {
+	return v.call("commit", append([]string{msg}, paths...), nil)
+}
+
+func (v *Plugin) Pull() error {
+	return v.call("pull", nil, nil)
+}
+
+func (v *Plugin) Push() error {
+	return v.call("push", nil, nil)
+}
+
+func (v *Plugin) Log(n int) ([]*LogEntry, error) {
+	var log []*pluginLogEntry
+	if err := v.call("log", []string{strconv.Itoa(n)}, &log); err != nil {
+		return nil, err
+	}
+	var list []*LogEntry
+	for _, e := range log {
+		list = append(list, &LogEntry{
+			ID:      e.ID,
+			Author:  e.Author,
+			Date:    e.Date,
+			Subject: e.Subject,
+		})
+	}
+	return list, nil
+}
+
+func (v *Plugin) call(method string, args []string, result any) error {
+	req, err := json.Marshal(&pluginRequest{
+		Version: pluginVersion,
+		Method:  method,
+		Args:    args,
+	})
+	if err != nil {
+		return err
+	}
+	name := pluginName(v.Cmd)
+	var stderr bytes.Buffer
+	cmd := v.Command()
+	cmd.Stdin = bytes.NewReader(req)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if stderr.Len() > 0 && v.UI != nil {
+		v.UI.Error(stderr.String())
+	}
+	var resp pluginResponse
+	switch {
+	case json.Unmarshal(out, &resp) == nil:
+	case err != nil:
+		return fmt.Errorf("%v: %w", name, err)
+	default:
+		return fmt.Errorf("%v: invalid response", name)
+	}
+	if resp.Output != "" && v.UI != nil {
+		v.UI.Print(resp.Output)
+	}
+	switch {
+	case resp.Error != "":
+		return errors.New(resp.Error)
+	case err != nil:
+		return fmt.Errorf("%v: %w", name, err)
+	case result != nil && len(resp.Result) > 0:
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+	}
+	return nil
+}
+
+var (
+	pmu     sync.Mutex
+	pathEnv string
+	plugins map[string]*pluginEntry
+)
+
+type pluginEntry struct {
+	path  string
+	probe *Plugin
+}
+
+func findPlugin(ui UI, name, dir string) (VCS, error) {
+	p := lookupPlugins()[strings.ToLower(name)]
+	if p == nil {
+		return nil, nil
+	}
+	v, err := newPlugin(ui, dir, p.path)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func pluginFor(ui UI, dir string) (string, VCS) {
+	m := lookupPlugins()
+	for _, k := range sortKeys(m) {
+		p := m[k]
+		pmu.Lock()
+		probe := p.probe
+		pmu.Unlock()
+		if probe == nil {
+			v, err := newPlugin(ui, "", p.path)
+			if err != nil {
+				continue
+			}
+			pmu.Lock()
+			p.probe = v
+			pmu.Unlock()
+			probe = v
+		}
+		if _, err := os.Stat(filepath.Join(dir, probe.ctrlDir)); err == nil {
+			v := *probe
+			v.UI = ui
+			v.Dir = dir
+			return k, &v
+		}
+	}
+	return "", nil
+}
+
+func lookupPlugins() map[string]*pluginEntry {
+	pmu.Lock()
+	defer pmu.Unlock()
+
+	// rescan when PATH is changed
+	env := os.Getenv("PATH")
+	if plugins != nil && env == pathEnv {
+		return plugins
+	}
+	pathEnv = env
+	plugins = make(map[string]*pluginEntry)
+	for _, dir := range filepath.SplitList(env) {
+		// like exec.LookPath, do not run plugins in the current directory
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		list, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, de := range list {
+			n := pluginName(de.Name())
+			if !strings.HasPrefix(n, pluginPrefix) || len(n) == len(pluginPrefix) {
+				continue
+			}
+			k := strings.ToLower(n[len(pluginPrefix):])
+			if _, ok := plugins[k]; ok {
+				continue
+			}
+			p := filepath.Join(dir, de.Name())
+			if !isExecutable(p) {
+				continue
+			}
+			plugins[k] = &pluginEntry{path: p}
+		}
+	}
+	return plugins
+}
+
+func pluginName(path string) string {
+	name := filepath.Base(path)
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(name))
+		for _, e := range pathExt() {
+			if ext == e {
+				return name[:len(name)-len(ext)]
+			}
+		}
+	}
+	return name
+}
+
+func isExecutable(path string) bool {
+	fi, err := os.Stat(path)
+	switch {
+	case err != nil || fi.IsDir():
+		return false
+	case runtime.GOOS == "windows":
+		return pluginName(path) != filepath.Base(path)
+	}
+	return fi.Mode()&0o111 != 0
+}
+
+func pathExt() []string {
+	env := os.Getenv("PATHEXT")
+	if env == "" {
+		env = ".com;.exe;.bat;.cmd"
+	}
+	var list []string
+	for _, e := range strings.Split(strings.ToLower(env), ";") {
+		if e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
@@ -0,0 +1,282 @@
+//
+// nazuna :: plugin_test.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package nazuna_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattya/nazuna"
+)
+
+func TestPlugin(t *testing.T) {
+	sandbox(t)
+	mock(t, "nzn-vcs-mock")
+
+	ui := new(testUI)
+	vcs, err := nazuna.FindVCS(ui, "MOCK", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := vcs.String(), "Mock"; g != e {
+		t.Errorf("VCS.String() = %q, expected %q", g, e)
+	}
+	if err := vcs.Init("repo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vcs.Clone("repo", "wc"); err == nil {
+		t.Error("expected error")
+	}
+
+	vcs, err = nazuna.VCSFor(ui, "repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch vcs := vcs.(type) {
+	case *nazuna.Plugin:
+		if g, e := vcs.Dir, "repo"; g != e {
+			t.Errorf("VCS.Dir = %q, expected %q", g, e)
+		}
+	default:
+		t.Fatalf("expected *Plugin, got %T", vcs)
+	}
+	if err := vcs.Exec("status"); err != nil {
+		t.Error(err)
+	}
+	if err := touch("repo", "file"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mkdir("repo", "dir"); err != nil {
+		t.Fatal(err)
+	}
+	if err := touch("repo", "dir", "file"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vcs.Add("."); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		paths []string
+		list  []string
+	}{
+		{[]string{"."}, []string{"dir/file", "file"}},
+		{[]string{"dir"}, []string{"dir/file"}},
+		{[]string{"_"}, nil},
+	} {
+		list, err := nazuna.ListFiles(vcs, tt.paths...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g, e := list, tt.list; !reflect.DeepEqual(g, e) {
+			t.Errorf("expected %q, got %q", e, g)
+		}
+	}
+	st, err := vcs.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string]byte)
+	for _, s := range st {
+		m[s.Path] = s.Code
+	}
+	if g, e := m, map[string]byte{
+		"dir/file": 'A',
+		"file":     'A',
+	}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	if err := vcs.Commit("msg"); err != nil {
+		t.Error(err)
+	}
+	rev, err := vcs.Revision()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := rev, "msg"; g != e {
+		t.Errorf("VCS.Revision() = %q, expected %q", g, e)
+	}
+	log, err := vcs.Log(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := len(log), 1; g != e {
+		t.Fatalf("len(VCS.Log()) = %v, expected %v", g, e)
+	}
+	if g, e := log[0].Subject, "msg"; g != e {
+		t.Errorf("LogEntry.Subject = %q, expected %q", g, e)
+	}
+	if log[0].Date.IsZero() {
+		t.Error("expected LogEntry.Date")
+	}
+	// not implemented
+	if err := vcs.Update(); err == nil {
+		t.Error("expected error")
+	}
+	if err := vcs.Checkout(rev); err == nil {
+		t.Error("expected error")
+	}
+	// exit status
+	if err := vcs.Fetch(); err == nil {
+		t.Error("expected error")
+	}
+	// invalid response
+	if err := vcs.Pull(); err == nil {
+		t.Error("expected error")
+	}
+	// error response
+	switch err := vcs.Push(); {
+	case err == nil:
+		t.Error("expected error")
+	case err.Error() != "rejected":
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := nazuna.VCSFor(ui, "wc"); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := nazuna.FindVCS(ui, "fossil", ""); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestPluginPath(t *testing.T) {
+	sandbox(t)
+	mock(t, "nzn-vcs-mock")
+
+	bin := filepath.SplitList(os.Getenv("PATH"))[0]
+	if err := os.Chdir(bin); err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range []string{
+		".",
+		string(os.PathListSeparator) + ".",
+		filepath.Join("..", filepath.Base(bin)),
+	} {
+		t.Setenv("PATH", env)
+		if _, err := nazuna.FindVCS(new(testUI), "mock", ""); err == nil {
+			t.Errorf("PATH=%q: expected error", env)
+		}
+	}
+}
+
+func mockPlugin() int {
+	var req struct {
+		Method string
+		Args   []string
+	}
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	index := filepath.Join(".mock", "index")
+	load := func() (list []string) {
+		if data, err := os.ReadFile(index); err == nil {
+			json.Unmarshal(data, &list)
+		}
+		return
+	}
+	resp := make(map[string]any)
+	switch req.Method {
+	case "probe":
+		resp["result"] = map[string]string{
+			"name":    "Mock",
+			"ctrlDir": ".mock",
+		}
+	case "exec":
+		resp["output"] = strings.Join(req.Args, " ") + "\n"
+	case "init":
+		if err := os.MkdirAll(filepath.Join(req.Args[0], ".mock"), 0o777); err != nil {
+			resp["error"] = err.Error()
+		}
+	case "add":
+		set := make(map[string]bool)
+		for _, p := range load() {
+			set[p] = true
+		}
+		for _, p := range req.Args {
+			filepath.WalkDir(p, func(p string, de fs.DirEntry, err error) error {
+				switch {
+				case err != nil:
+					return err
+				case de.Name() == ".mock":
+					return filepath.SkipDir
+				case !de.IsDir():
+					set[filepath.ToSlash(p)] = true
+				}
+				return nil
+			})
+		}
+		list := make([]string, 0, len(set))
+		for p := range set {
+			list = append(list, p)
+		}
+		sort.Strings(list)
+		data, _ := json.Marshal(list)
+		if err := os.WriteFile(index, data, 0o666); err != nil {
+			resp["error"] = err.Error()
+		}
+	case "list":
+		var list []string
+		for _, p := range load() {
+			for _, a := range req.Args {
+				if a == "." || p == a || strings.HasPrefix(p, a+"/") {
+					list = append(list, p)
+					break
+				}
+			}
+		}
+		resp["result"] = list
+	case "status":
+		var list []map[string]string
+		for _, p := range load() {
+			list = append(list, map[string]string{
+				"code": "A",
+				"path": p,
+			})
+		}
+		resp["result"] = list
+	case "commit":
+		if err := os.WriteFile(filepath.Join(".mock", "commit"), []byte(req.Args[0]), 0o666); err != nil {
+			resp["error"] = err.Error()
+		}
+		fmt.Fprintln(os.Stderr, "committed")
+	case "revision":
+		data, _ := os.ReadFile(filepath.Join(".mock", "commit"))
+		resp["result"] = string(data)
+	case "log":
+		data, _ := os.ReadFile(filepath.Join(".mock", "commit"))
+		resp["result"] = []map[string]any{{
+			"id":      string(data),
+			"author":  "Nazuna <nazuna@example.com>",
+			"date":    time.Now().Format(time.RFC3339),
+			"subject": string(data),
+		}}
+	case "fetch":
+		return 1
+	case "pull":
+		io.WriteString(os.Stdout, "{")
+		return 0
+	case "push":
+		io.WriteString(os.Stdout, `{"error": "rejected"}`)
+		return 1
+	default:
+		resp["error"] = fmt.Sprintf("mock: '%v' is not supported", req.Method)
+	}
+	json.NewEncoder(os.Stdout).Encode(resp)
+	return 0
+}
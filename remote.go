@@ -1,7 +1,7 @@
 //
 // nazuna :: remote.go
 //
-//   Copyright (c) 2013-2020 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -9,48 +9,83 @@
 package nazuna
 
 import (
+	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 )
 
 var ErrRemote = errors.New("unknown remote")
 
 type Remote struct {
-	VCS  string
-	URI  string
-	Root string
-	Path string
+	VCS   string
+	URI   string
+	Root  string
+	Path  string
+	Rev   string
+	Build string
 
 	ui  UI
 	src string
 }
 
 func NewRemote(ui UI, src string) (*Remote, error) {
-	for _, rh := range remoteHandlers {
-		if !strings.HasPrefix(src, rh.Prefix) {
-			continue
-		}
-		m := rh.rx.FindStringSubmatch(src)
-		if m == nil {
-			continue
+	return newRemote(ui, src, defaultCache, nil)
+}
+
+func newRemote(ui UI, src string, c *remoteCache, hc *HTTPClient) (*Remote, error) {
+	if rh, g := matchRemote(src); g != nil {
+		if rh.Check != nil || rh.check != nil {
+			if e := c.remote(g["root"]); e != nil {
+				g["vcs"] = e.VCS
+				g["uri"] = e.URI
+			} else {
+				var err error
+				if rh.check != nil {
+					err = rh.check(hc, g)
+				} else {
+					err = rh.Check(g)
+				}
+				if err != nil {
+					return nil, err
+				}
+				c.addRemote(g["root"], g["vcs"], g["uri"])
+			}
 		}
-		g := map[string]string{
-			"vcs": rh.VCS,
+		r := &Remote{
+			VCS:  g["vcs"],
+			URI:  g["uri"],
+			Root: g["root"],
+			Path: g["path"],
+			ui:   ui,
+			src:  src,
 		}
-		for i, n := range rh.rx.SubexpNames() {
-			if n != "" && g[n] == "" {
-				g[n] = m[i]
-			}
+		return r, nil
+	}
+	if g := parseURL(src); g != nil {
+		if g["vcs"] == "" && g["local"] != "" {
+			g["vcs"] = vcsOf(g["local"])
 		}
-		g["uri"] = rh.Scheme + "://" + g["root"]
-		if rh.Check != nil {
-			if err := rh.Check(g); err != nil {
-				return nil, err
-			}
+		r := &Remote{
+			VCS:  g["vcs"],
+			URI:  g["uri"],
+			Root: g["root"],
+			ui:   ui,
+			src:  src,
 		}
+		return r, nil
+	}
+	switch g, err := goImport(src, c, hc); {
+	case err != nil:
+		return nil, err
+	case g != nil:
 		r := &Remote{
 			VCS:  g["vcs"],
 			URI:  g["uri"],
@@ -69,7 +104,19 @@ func (r *Remote) Clone(base, dst string) error {
 	if err != nil {
 		return fmt.Errorf("cannot detect remote vcs for %v", r.src)
 	}
-	return vcs.Clone(r.URI, dst)
+	defer closeVCS(vcs)
+	if err := vcs.Clone(r.URI, dst); err != nil || r.Rev == "" {
+		return err
+	}
+	if !filepath.IsAbs(dst) {
+		dst = filepath.Join(base, dst)
+	}
+	clone, err := FindVCS(r.ui, r.VCS, dst)
+	if err != nil {
+		return err
+	}
+	defer closeVCS(clone)
+	return clone.Checkout(r.Rev)
 }
 
 func (r *Remote) Update(dir string) error {
@@ -77,7 +124,80 @@ func (r *Remote) Update(dir string) error {
 	if err != nil {
 		return err
 	}
-	return vcs.Update()
+	defer closeVCS(vcs)
+	if r.Rev == "" {
+		return vcs.Update()
+	}
+	if err := vcs.Fetch(); err != nil {
+		return err
+	}
+	return vcs.Checkout(r.Rev)
+}
+
+func (r *Remote) Revision(dir string) (string, error) {
+	vcs, err := VCSFor(r.ui, dir)
+	if err != nil {
+		return "", err
+	}
+	defer closeVCS(vcs)
+	return vcs.Revision()
+}
+
+func (r *Remote) RunBuild(dir string) error {
+	if r.Build == "" {
+		return nil
+	}
+	prefix := SubrepoName(r.Root) + ": "
+	stdout := &prefixWriter{
+		prefix: prefix,
+		print:  r.ui.Print,
+	}
+	stderr := &prefixWriter{
+		prefix: prefix,
+		print:  r.ui.Error,
+	}
+	cmd := shell(r.Build)
+	cmd.Dir = dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	stdout.Flush()
+	stderr.Flush()
+	if err != nil {
+		return fmt.Errorf("build of %v failed: %w", r.Root, err)
+	}
+	return nil
+}
+
+type prefixWriter struct {
+	prefix string
+	print  func(...any) (int, error)
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := w.print(w.prefix + string(w.buf[:i+1])); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *prefixWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	// incomplete line
+	_, err := w.print(w.prefix + string(w.buf) + "\n")
+	w.buf = nil
+	return err
 }
 
 type RemoteHandler struct {
@@ -87,23 +207,29 @@ type RemoteHandler struct {
 	Scheme string
 	Check  func(map[string]string) error
 
-	rx *regexp.Regexp
+	rx    *regexp.Regexp
+	check func(*HTTPClient, map[string]string) error
 }
 
-var remoteHandlers = []*RemoteHandler{
-	{
-		Prefix: "github.com/",
-		Expr:   `^(?P<root>github\.com/[^/]+/[^/]+)(?P<path>.*)$`,
-		VCS:    "git",
-		Scheme: "https",
-	},
-	{
-		Prefix: "bitbucket.org/",
-		Expr:   `^(?P<root>bitbucket\.org/(?P<repo>[^/]+/[^/]+))(?P<path>.*)$`,
-		Scheme: "https",
-		Check:  bitbucket,
-	},
-}
+var (
+	rmu            sync.RWMutex
+	remoteHandlers = []*RemoteHandler{
+		{
+			Prefix: "github.com/",
+			Expr:   `^(?P<root>github\.com/[^/]+/[^/]+)(?P<path>.*)$`,
+			VCS:    "git",
+			Scheme: "https",
+		},
+		{
+			Prefix: "bitbucket.org/",
+			Expr:   `^(?P<root>bitbucket\.org/(?P<repo>[^/]+/[^/]+))(?P<path>.*)$`,
+			Scheme: "https",
+			check:  bitbucket,
+		},
+	}
+	// number of registered handlers
+	nremotes int
+)
 
 func init() {
 	for _, r := range remoteHandlers {
@@ -111,12 +237,293 @@ func init() {
 	}
 }
 
-func bitbucket(m map[string]string) error {
+// matchRemote returns the handler which matches src, and the handler can be
+// used without the lock
+func matchRemote(src string) (*RemoteHandler, map[string]string) {
+	rmu.RLock()
+	defer rmu.RUnlock()
+
+	for _, rh := range remoteHandlers {
+		if g := rh.match(src); g != nil {
+			return rh, g
+		}
+	}
+	return nil, nil
+}
+
+func remotePath(src string) string {
+	if _, g := matchRemote(src); g != nil {
+		return g["root"] + g["path"]
+	}
+	if g := parseURL(src); g != nil {
+		return g["root"]
+	}
+	return src
+}
+
+var scpRx = regexp.MustCompile(`^(?:[^@/]+@)?([^@/:]{2,}):(.+)$`)
+
+func parseURL(src string) map[string]string {
+	g := make(map[string]string)
+	var p string
+	switch {
+	case filepath.IsAbs(src):
+		g["uri"] = src
+		g["local"] = src
+		p = "file" + path.Clean("/"+strings.Replace(filepath.ToSlash(src), ":", "", 1))
+	case strings.Contains(src, "://"):
+		u, err := url.Parse(src)
+		if err != nil {
+			return nil
+		}
+		scheme := strings.ToLower(u.Scheme)
+		for _, k := range []string{"git", "hg"} {
+			if strings.HasPrefix(scheme, k+"+") {
+				g["vcs"] = k
+				scheme = scheme[len(k)+1:]
+			}
+		}
+		switch scheme {
+		case "git":
+			g["vcs"] = "git"
+		case "http", "https", "ssh":
+		case "file":
+			g["local"] = filepath.FromSlash(u.Path)
+			if len(g["local"]) > 1 && filepath.VolumeName(g["local"][1:]) != "" {
+				g["local"] = g["local"][1:]
+			}
+		default:
+			return nil
+		}
+		u.Scheme = scheme
+		g["uri"] = u.String()
+		if scheme == "file" {
+			p = "file" + path.Clean("/"+strings.Replace(u.Path, ":", "", 1))
+		} else {
+			p = u.Hostname() + path.Clean("/"+u.Path)
+		}
+	default:
+		// scp-like syntax
+		m := scpRx.FindStringSubmatch(src)
+		if m == nil {
+			return nil
+		}
+		g["vcs"] = "git"
+		g["uri"] = src
+		p = m[1] + path.Clean("/"+m[2])
+	}
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if strings.HasSuffix(p, ".git") {
+		p = p[:len(p)-4]
+		if g["vcs"] == "" {
+			g["vcs"] = "git"
+		}
+	}
+	g["root"] = p
+	return g
+}
+
+type remoteCache struct {
+	Remotes map[string]*cacheEntry `json:"remote,omitempty"`
+	Imports map[string]*cacheEntry `json:"go-import,omitempty"`
+
+	mu    sync.Mutex
+	dirty bool
+}
+
+type cacheEntry struct {
+	VCS string `json:"vcs"`
+	URI string `json:"uri"`
+}
+
+var defaultCache = new(remoteCache)
+
+func (c *remoteCache) remote(root string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.Remotes[root]
+}
+
+func (c *remoteCache) addRemote(root, vcs, uri string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Remotes == nil {
+		c.Remotes = make(map[string]*cacheEntry)
+	}
+	c.Remotes[root] = &cacheEntry{
+		VCS: vcs,
+		URI: uri,
+	}
+	c.dirty = true
+}
+
+func (c *remoteCache) lookup(src string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var root string
+	for p := range c.Imports {
+		if len(p) > len(root) && hasPathPrefix(src, p) {
+			root = p
+		}
+	}
+	if root == "" {
+		return nil
+	}
+	return map[string]string{
+		"vcs":  c.Imports[root].VCS,
+		"uri":  c.Imports[root].URI,
+		"root": root,
+		"path": src[len(root):],
+	}
+}
+
+func (c *remoteCache) addImport(root, vcs, uri string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Imports == nil {
+		c.Imports = make(map[string]*cacheEntry)
+	}
+	c.Imports[root] = &cacheEntry{
+		VCS: vcs,
+		URI: uri,
+	}
+	c.dirty = true
+}
+
+func goImport(src string, c *remoteCache, hc *HTTPClient) (map[string]string, error) {
+	host, _, _ := strings.Cut(src, "/")
+	if !strings.Contains(host, ".") || strings.HasPrefix(host, ".") || strings.ContainsAny(src, `:\?#`) {
+		return nil, nil
+	}
+	if g := c.lookup(src); g != nil {
+		return g, nil
+	}
+	data, err := httpGet(hc, "https://"+src+"?go-get=1")
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range metaImports(data) {
+		if (f[1] == "git" || f[1] == "hg") && hasPathPrefix(src, f[0]) {
+			c.addImport(f[0], f[1], f[2])
+			return c.lookup(src), nil
+		}
+	}
+	return nil, nil
+}
+
+func metaImports(data []byte) (list [][]string) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	for {
+		tok, err := d.RawToken()
+		if err != nil {
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch strings.ToLower(t.Name.Local) {
+			case "body":
+				return
+			case "meta":
+				var name, content string
+				for _, a := range t.Attr {
+					switch strings.ToLower(a.Name.Local) {
+					case "name":
+						name = a.Value
+					case "content":
+						content = a.Value
+					}
+				}
+				if name == "go-import" {
+					if f := strings.Fields(content); len(f) == 3 {
+						list = append(list, f)
+					}
+				}
+			}
+		case xml.EndElement:
+			if strings.ToLower(t.Name.Local) == "head" {
+				return
+			}
+		}
+	}
+}
+
+func hasPathPrefix(s, prefix string) bool {
+	return s == prefix || strings.HasPrefix(s, prefix+"/")
+}
+
+func (rh *RemoteHandler) match(src string) map[string]string {
+	if !strings.HasPrefix(src, rh.Prefix) {
+		return nil
+	}
+	m := rh.rx.FindStringSubmatch(src)
+	if m == nil {
+		return nil
+	}
+	g := map[string]string{
+		"vcs": rh.VCS,
+	}
+	for i, n := range rh.rx.SubexpNames() {
+		if n != "" && g[n] == "" {
+			g[n] = m[i]
+		}
+	}
+	g["uri"] = rh.Scheme + "://" + g["root"]
+	return g
+}
+
+func RegisterRemote(rh *RemoteHandler) error {
+	rx, err := regexp.Compile(rh.Expr)
+	if err != nil {
+		return fmt.Errorf("remote '%v': %v", rh.Prefix, err)
+	}
+	root := false
+	for _, n := range rx.SubexpNames() {
+		if n == "root" {
+			root = true
+			break
+		}
+	}
+	switch {
+	case !root:
+		return fmt.Errorf("remote '%v': group 'root' is not defined", rh.Prefix)
+	case rh.VCS == "" && rh.Check == nil:
+		return fmt.Errorf("remote '%v': vcs is not specified", rh.Prefix)
+	}
+	h := *rh
+	h.rx = rx
+	if h.Scheme == "" {
+		h.Scheme = "https"
+	}
+
+	rmu.Lock()
+	defer rmu.Unlock()
+
+	for i := 0; i < nremotes; i++ {
+		if remoteHandlers[i].Prefix == h.Prefix {
+			remoteHandlers[i] = &h
+			return nil
+		}
+	}
+	remoteHandlers = append(remoteHandlers, nil)
+	copy(remoteHandlers[nremotes+1:], remoteHandlers[nremotes:])
+	remoteHandlers[nremotes] = &h
+	nremotes++
+	return nil
+}
+
+func bitbucket(hc *HTTPClient, m map[string]string) error {
 	var resp struct {
 		SCM string
 	}
 	uri := "https://api.bitbucket.org/2.0/repositories/" + m["repo"]
-	data, err := httpGet(uri)
+	data, err := httpGet(hc, uri)
 	if err != nil {
 		return err
 	}
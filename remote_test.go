@@ -1,7 +1,7 @@
 //
 // nazuna :: remote_test.go
 //
-//   Copyright (c) 2013-2022 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -45,6 +45,46 @@ var remoteTests = []struct {
 		uri:  "https://bitbucket.org/hattya/hg",
 		root: "bitbucket.org/hattya/hg",
 	},
+	{
+		src:  "https://gitlab.example.com/group/repo.git",
+		vcs:  "git",
+		uri:  "https://gitlab.example.com/group/repo.git",
+		root: "gitlab.example.com/group/repo",
+	},
+	{
+		src:  "ssh://hg@example.com:2222/repo/",
+		uri:  "ssh://hg@example.com:2222/repo/",
+		root: "example.com/repo",
+	},
+	{
+		src:  "hg+https://example.com/hg/repo",
+		vcs:  "hg",
+		uri:  "https://example.com/hg/repo",
+		root: "example.com/hg/repo",
+	},
+	{
+		src:  "git://example.com/repo",
+		vcs:  "git",
+		uri:  "git://example.com/repo",
+		root: "example.com/repo",
+	},
+	{
+		src:  "git@example.com:user/repo.git",
+		vcs:  "git",
+		uri:  "git@example.com:user/repo.git",
+		root: "example.com/user/repo",
+	},
+	{
+		src:  "file:///srv/git/repo.git",
+		vcs:  "git",
+		uri:  "file:///srv/git/repo.git",
+		root: "file/srv/git/repo",
+	},
+	{
+		src:  "https://example.com/../../repo",
+		uri:  "https://example.com/../../repo",
+		root: "example.com/repo",
+	},
 }
 
 func TestNewRemote(t *testing.T) {
@@ -99,8 +139,15 @@ func TestNewRemoteError(t *testing.T) {
 	defer func() { http.DefaultClient = save }()
 	http.DefaultClient = test.NewHTTPClient(s.Listener.Addr().String())
 
-	if _, err := nazuna.NewRemote(nil, "github.com/hattya"); err != nazuna.ErrRemote {
-		t.Errorf("expected ErrRemote, got %v", err)
+	for _, src := range []string{
+		"github.com/hattya",
+		"ftp://example.com/repo",
+		"example.com/repo",
+		"C:repo",
+	} {
+		if _, err := nazuna.NewRemote(nil, src); err != nazuna.ErrRemote {
+			t.Errorf("expected ErrRemote, got %v", err)
+		}
 	}
 
 	switch _, err := nazuna.NewRemote(nil, "bitbucket.org/hattya/_"); {
@@ -118,6 +165,172 @@ func TestNewRemoteError(t *testing.T) {
 	}
 }
 
+func TestNewRemoteGoImport(t *testing.T) {
+	var n int
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("go-get") != "1" {
+			http.NotFound(w, r)
+			return
+		}
+		n++
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/git"):
+			fmt.Fprintln(w, `<!DOCTYPE html>`)
+			fmt.Fprintln(w, `<html><head>`)
+			fmt.Fprintln(w, `<meta name="go-import" content="go.example.com/git mod https://go.example.com/mod">`)
+			fmt.Fprintln(w, `<meta name="go-import" content="go.example.com/git git https://git.example.com/git.git">`)
+			fmt.Fprintln(w, `</head><body>`)
+			fmt.Fprintln(w, `<meta name="go-import" content="go.example.com/git hg https://hg.example.com/git">`)
+			fmt.Fprintln(w, `</body></html>`)
+		case strings.HasPrefix(r.URL.Path, "/hg"):
+			fmt.Fprintln(w, `<meta name=go-import content="go.example.com/hg hg https://hg.example.com/hg">`)
+		}
+	}))
+	defer s.Close()
+
+	save := http.DefaultClient
+	defer func() { http.DefaultClient = save }()
+	http.DefaultClient = test.NewHTTPClient(s.Listener.Addr().String())
+
+	for _, tt := range []struct {
+		src                  string
+		vcs, uri, root, path string
+	}{
+		{
+			src:  "go.example.com/git",
+			vcs:  "git",
+			uri:  "https://git.example.com/git.git",
+			root: "go.example.com/git",
+		},
+		{
+			src:  "go.example.com/git/vim",
+			vcs:  "git",
+			uri:  "https://git.example.com/git.git",
+			root: "go.example.com/git",
+			path: "/vim",
+		},
+		{
+			src:  "go.example.com/hg/vim",
+			vcs:  "hg",
+			uri:  "https://hg.example.com/hg",
+			root: "go.example.com/hg",
+			path: "/vim",
+		},
+	} {
+		r, err := nazuna.NewRemote(nil, tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g, e := r.VCS, tt.vcs; g != e {
+			t.Errorf("Remote.VCS = %v, expected %v", g, e)
+		}
+		if g, e := r.URI, tt.uri; g != e {
+			t.Errorf("Remote.URI = %v, expected %v", g, e)
+		}
+		if g, e := r.Root, tt.root; g != e {
+			t.Errorf("Remote.Root = %v, expected %v", g, e)
+		}
+		if g, e := r.Path, tt.path; g != e {
+			t.Errorf("Remote.Path = %v, expected %v", g, e)
+		}
+	}
+	if g, e := n, 2; g != e {
+		t.Errorf("expected %v requests, got %v", e, g)
+	}
+
+	for _, src := range []string{
+		"go.example.com/_",
+		"go.example.com/gitlab",
+		"go.example.com/git?",
+		"../go.example.com/git",
+	} {
+		if _, err := nazuna.NewRemote(nil, src); err != nazuna.ErrRemote {
+			t.Errorf("expected ErrRemote, got %v", err)
+		}
+	}
+}
+
+func TestRegisterRemote(t *testing.T) {
+	rh := &nazuna.RemoteHandler{
+		Prefix: "git.example.com/",
+		Expr:   `^(?P<root>git\.example\.com/[^/]+/[^/]+)(?P<path>.*)$`,
+		VCS:    "hg",
+	}
+	if err := nazuna.RegisterRemote(rh); err != nil {
+		t.Fatal(err)
+	}
+	r, err := nazuna.NewRemote(nil, "git.example.com/hattya/nazuna/path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := r.VCS, "hg"; g != e {
+		t.Errorf("Remote.VCS = %v, expected %v", g, e)
+	}
+	if g, e := r.URI, "https://git.example.com/hattya/nazuna"; g != e {
+		t.Errorf("Remote.URI = %v, expected %v", g, e)
+	}
+	if g, e := r.Root, "git.example.com/hattya/nazuna"; g != e {
+		t.Errorf("Remote.Root = %v, expected %v", g, e)
+	}
+	if g, e := r.Path, "/path"; g != e {
+		t.Errorf("Remote.Path = %v, expected %v", g, e)
+	}
+	// replace
+	rh.VCS = "git"
+	rh.Scheme = "ssh"
+	if err := nazuna.RegisterRemote(rh); err != nil {
+		t.Fatal(err)
+	}
+	r, err = nazuna.NewRemote(nil, "git.example.com/hattya/nazuna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := r.VCS, "git"; g != e {
+		t.Errorf("Remote.VCS = %v, expected %v", g, e)
+	}
+	if g, e := r.URI, "ssh://git.example.com/hattya/nazuna"; g != e {
+		t.Errorf("Remote.URI = %v, expected %v", g, e)
+	}
+	// override
+	if err := nazuna.RegisterRemote(&nazuna.RemoteHandler{
+		Prefix: "github.com/nazuna/",
+		Expr:   `^(?P<root>github\.com/nazuna/[^/]+)(?P<path>.*)$`,
+		VCS:    "hg",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r, err = nazuna.NewRemote(nil, "github.com/nazuna/nazuna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := r.VCS, "hg"; g != e {
+		t.Errorf("Remote.VCS = %v, expected %v", g, e)
+	}
+}
+
+func TestRegisterRemoteError(t *testing.T) {
+	for _, rh := range []*nazuna.RemoteHandler{
+		{
+			Prefix: "example.com/",
+			Expr:   `^(?P<root>example\.com/[^/]+`,
+			VCS:    "git",
+		},
+		{
+			Prefix: "example.com/",
+			Expr:   `^example\.com/[^/]+$`,
+			VCS:    "git",
+		},
+		{
+			Prefix: "example.com/",
+			Expr:   `^(?P<root>example\.com/[^/]+)$`,
+		},
+	} {
+		if err := nazuna.RegisterRemote(rh); err == nil {
+			t.Error("expected error")
+		}
+	}
+}
+
 func TestRemote(t *testing.T) {
 	dir := sandbox(t)
 	home := filepath.Join(dir, "home")
@@ -163,10 +376,112 @@ func TestRemote(t *testing.T) {
 		t.Error(err)
 	}
 
+	rev, err := r.Revision(filepath.Join(home, filepath.Base(r.Root)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Revision(filepath.Base(r.Root)); err == nil {
+		t.Error("expected error")
+	}
+	// pinned
+	r.Rev = rev
+	if err := r.Clone(home, "pinned"); err != nil {
+		t.Error(err)
+	}
+	if err := r.Update(filepath.Join(home, "pinned")); err != nil {
+		t.Log(ui.String())
+		t.Error(err)
+	}
+	if g, err := r.Revision(filepath.Join(home, "pinned")); err != nil {
+		t.Error(err)
+	} else if g != rev {
+		t.Errorf("Remote.Revision() = %v, expected %v", g, rev)
+	}
+	// unpinned
+	r.Rev = ""
+	if err := r.Update(filepath.Join(home, "pinned")); err != nil {
+		t.Log(ui.String())
+		t.Error(err)
+	}
+	git(t, "-C", filepath.Join(home, "pinned"), "symbolic-ref", "-q", "HEAD")
+
+	r.Rev = "_"
+	if err := r.Update(filepath.Join(home, "pinned")); err == nil {
+		t.Error("expected error")
+	}
+	if err := r.Clone(home, "_"); err == nil {
+		t.Error("expected error")
+	}
+	r.Rev = ""
+
 	r.VCS = "cvs"
 	if err := r.Clone(home, filepath.Base(r.Root)); err == nil {
 		t.Error("expected error")
 	}
+
+	// local repository
+	git(t, "clone", "-q", "--bare", filepath.Join(dir, "public", "gist-vim"), filepath.Join(dir, "public", "bare"))
+	for _, src := range []string{
+		filepath.Join(dir, "public", "gist-vim"),
+		filepath.Join(dir, "public", "bare"),
+	} {
+		r, err := nazuna.NewRemote(ui, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g, e := r.VCS, "git"; g != e {
+			t.Errorf("Remote.VCS = %v, expected %v", g, e)
+		}
+		if err := r.Clone(home, "local-"+filepath.Base(src)); err != nil {
+			t.Log(ui.String())
+			t.Error(err)
+		}
+	}
+	r, err = nazuna.NewRemote(ui, filepath.Join(dir, "public"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := r.VCS, ""; g != e {
+		t.Errorf("Remote.VCS = %v, expected %v", g, e)
+	}
+}
+
+func TestRemoteRunBuild(t *testing.T) {
+	dir := sandbox(t)
+
+	ui := new(printUI)
+	r, err := nazuna.NewRemote(ui, "github.com/hattya/nazuna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RunBuild(dir); err != nil {
+		t.Error(err)
+	}
+	if g, e := ui.out.String(), ""; g != e {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+
+	r.Build = "echo foo"
+	if err := r.RunBuild(dir); err != nil {
+		t.Error(err)
+	}
+	if g, e := strings.ReplaceAll(ui.out.String(), "\r\n", "\n"), "nazuna: foo\n"; g != e {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+
+	r.Build = "exit 1"
+	if err := r.RunBuild(dir); err == nil {
+		t.Error("expected error")
+	}
+}
+
+type printUI struct {
+	testUI
+	out bytes.Buffer
+}
+
+func (ui *printUI) Print(a ...any) (int, error) {
+	return fmt.Fprint(&ui.out, a...)
 }
 
 func git(t *testing.T, a ...string) {
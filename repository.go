@@ -1,7 +1,7 @@
 //
 // nazuna :: repository.go
 //
-//   Copyright (c) 2013-2021 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -9,8 +9,8 @@
 package nazuna
 
 import (
-	"bufio"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
@@ -26,7 +26,10 @@ func Discover(b bool) bool {
 }
 
 type Repository struct {
-	Layers []*Layer
+	Layers     []*Layer
+	Lock       Lock
+	Config     Config
+	HTTPClient *HTTPClient
 
 	ui      UI
 	vcs     VCS
@@ -34,6 +37,10 @@ type Repository struct {
 	nzndir  string
 	rdir    string
 	subroot string
+	cache   *remoteCache
+	files   []string
+	destdir string
+	orig    *Repository
 }
 
 func Open(ui UI, path string) (*Repository, error) {
@@ -50,33 +57,209 @@ func Open(ui UI, path string) (*Repository, error) {
 	}
 
 	nzndir := filepath.Join(root, ".nzn")
-	rdir := filepath.Join(nzndir, "r")
-	vcs, err := VCSFor(ui, rdir)
-	if err != nil {
-		return nil, err
-	}
 	repo := &Repository{
 		ui:      ui,
-		vcs:     vcs,
 		root:    root,
 		nzndir:  nzndir,
-		rdir:    rdir,
+		rdir:    filepath.Join(nzndir, "r"),
 		subroot: filepath.Join(nzndir, "sub"),
 	}
+	if err := unmarshal(repo, filepath.Join(nzndir, "config.json"), &repo.Config); err != nil {
+		return nil, err
+	}
+	// working copy outside of the repository
+	if repo.Config.Target != "" {
+		target := filepath.FromSlash(os.ExpandEnv(repo.Config.Target))
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(root, target)
+		}
+		repo.root = filepath.Clean(target)
+	}
+	// prefer the recorded vcs
+	if repo.Config.VCS != "" {
+		repo.vcs, err = FindVCS(ui, repo.Config.VCS, repo.rdir)
+	} else {
+		repo.vcs, err = VCSFor(ui, repo.rdir)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	if err := unmarshal(repo, filepath.Join(repo.rdir, "nazuna.json"), &repo.Layers); err != nil {
+	if err := repo.Reload(); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+func (repo *Repository) Deploy(dir string) (*Repository, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
 		return nil, err
 	}
+	// the vcs, the cache and the layers are shared with repo
+	d := *repo
+	d.destdir = dir
+	d.orig = repo
+	d.root = d.destPath(repo.root)
+	d.nzndir = d.destPath(repo.nzndir)
+	d.rdir = filepath.Join(d.nzndir, "r")
+	d.subroot = filepath.Join(d.nzndir, "sub")
+	d.files = nil
+	return &d, nil
+}
+
+func (repo *Repository) Snapshot() error {
+	if repo.orig == nil {
+		return fmt.Errorf("repository is not deployed")
+	}
+	keep := make(map[string]bool)
+	err := repo.orig.Walk(".", func(p string, _ os.FileInfo, err error) error {
+		switch {
+		case os.IsNotExist(err):
+			return nil
+		case err != nil:
+			return err
+		}
+		dst := repo.PathFor(nil, p)
+		keep[dst] = true
+		return copyFile(repo.orig.PathFor(nil, p), dst)
+	})
+	if err != nil {
+		return err
+	}
+	for _, sub := range repo.Subrepos() {
+		src := repo.orig.SubrepoFor(sub.Src)
+		if !IsDir(src) {
+			continue
+		}
+		dst := repo.SubrepoFor(sub.Src)
+		err := filepath.WalkDir(src, func(p string, de fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(src, p)
+			if err != nil {
+				return err
+			}
+			keep[filepath.Join(dst, rel)] = true
+			if de.IsDir() {
+				return nil
+			}
+			return copyFile(p, filepath.Join(dst, rel))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// files which were removed after the last snapshot
+	for _, root := range []string{repo.rdir, repo.subroot} {
+		if err := prune(root, keep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prune removes the files under root which are not kept, and the directories
+// which become empty
+func prune(root string, keep map[string]bool) error {
+	var dirs []string
+	err := filepath.WalkDir(root, func(p string, de fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			if p == root && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		case de.IsDir():
+			if p != root && !keep[p] {
+				dirs = append(dirs, p)
+			}
+			return nil
+		case keep[p]:
+			return nil
+		}
+		return os.Remove(p)
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if IsEmptyDir(dirs[i]) {
+			if err := os.Remove(dirs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// destPath returns the path under the alternate root
+func (repo *Repository) destPath(path string) string {
+	if repo.destdir == "" {
+		return path
+	}
+	return filepath.Join(repo.destdir, path[len(filepath.VolumeName(path)):])
+}
+
+// imagePath returns the path without the alternate root
+func (repo *Repository) imagePath(path string) string {
+	if repo.destdir == "" || !strings.HasPrefix(path, repo.destdir+string(os.PathSeparator)) {
+		return path
+	}
+	return path[len(repo.destdir):]
+}
+
+func (repo *Repository) Close() error {
+	return closeVCS(repo.vcs)
+}
+
+func (repo *Repository) Reload() error {
+	repo.files = nil
+	repo.Layers = nil
+	if err := unmarshal(repo, filepath.Join(repo.rdir, "nazuna.json"), &repo.Layers); err != nil {
+		return err
+	}
 	if repo.Layers == nil {
 		repo.Layers = []*Layer{}
 	}
-	return repo, nil
+	repo.Lock = Lock{}
+	return unmarshal(repo, filepath.Join(repo.rdir, "nazuna.lock"), &repo.Lock)
 }
 
 func (repo *Repository) Flush() error {
 	return marshal(repo, filepath.Join(repo.rdir, "nazuna.json"), repo.Layers)
 }
 
+func (repo *Repository) FlushLock() error {
+	return marshal(repo, filepath.Join(repo.rdir, "nazuna.lock"), &repo.Lock)
+}
+
+func (repo *Repository) FlushConfig() error {
+	return marshal(repo, filepath.Join(repo.nzndir, "config.json"), &repo.Config)
+}
+
+func (repo *Repository) NewRemote(src string) (*Remote, error) {
+	path := filepath.Join(repo.nzndir, "cache.json")
+	if repo.cache == nil {
+		repo.cache = new(remoteCache)
+		if err := unmarshal(repo, path, repo.cache); err != nil {
+			return nil, err
+		}
+	}
+	r, err := newRemote(repo.ui, src, repo.cache, repo.HTTPClient)
+	if err != nil {
+		return nil, err
+	}
+	if repo.cache.dirty {
+		if err := marshal(repo, path, repo.cache); err != nil {
+			return nil, err
+		}
+		repo.cache.dirty = false
+	}
+	return r, nil
+}
+
 func (repo *Repository) LayerOf(name string) (*Layer, error) {
 	n, err := repo.splitLayer(name)
 	if err != nil {
@@ -131,6 +314,7 @@ func (repo *Repository) NewLayer(name string) (*Layer, error) {
 		l = ll
 	}
 	os.MkdirAll(repo.PathFor(l, "/"), 0o777)
+	repo.files = nil
 	return l, nil
 }
 
@@ -163,7 +347,63 @@ func (repo *Repository) PathFor(layer *Layer, path string) string {
 }
 
 func (repo *Repository) SubrepoFor(path string) string {
-	return filepath.Join(repo.subroot, path)
+	return filepath.Join(repo.subroot, remotePath(path))
+}
+
+func (repo *Repository) Subrepos() []*Subrepo {
+	var list []*Subrepo
+	var walk func([]*Layer)
+	walk = func(layers []*Layer) {
+		for _, l := range layers {
+			walk(l.Layers)
+			for _, dir := range sortKeys(l.Subrepos) {
+				list = append(list, l.Subrepos[dir]...)
+			}
+		}
+	}
+	walk(repo.Layers)
+	return list
+}
+
+func (repo *Repository) Clones() ([]string, error) {
+	var list []string
+	err := filepath.WalkDir(repo.subroot, func(path string, de fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			if path == repo.subroot && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		case !de.IsDir() || path == repo.subroot:
+			return nil
+		}
+		if _, err := VCSFor(repo.ui, path); err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(repo.subroot, path)
+		if err != nil {
+			return err
+		}
+		list = append(list, filepath.ToSlash(rel))
+		return filepath.SkipDir
+	})
+	return list, err
+}
+
+func (repo *Repository) RemoveClone(path string) error {
+	path = repo.SubrepoFor(path)
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	for p := filepath.Dir(path); p != repo.subroot; p = filepath.Dir(p) {
+		if !IsEmptyDir(p) {
+			break
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (repo *Repository) WC() (*WC, error) {
@@ -197,7 +437,7 @@ func (repo *Repository) Find(layer *Layer, path string) (typ string) {
 	}
 
 	for _, s := range layer.Subrepos[dir] {
-		if s.Name == name || filepath.Base(s.Src) == name {
+		if s.Name == name || SubrepoName(s.Src) == name {
 			return "subrepo"
 		}
 	}
@@ -205,31 +445,103 @@ func (repo *Repository) Find(layer *Layer, path string) (typ string) {
 }
 
 func (repo *Repository) Walk(path string, walk filepath.WalkFunc) error {
-	cmd := repo.vcs.List(path)
-	out, err := cmd.StdoutPipe()
-	if err != nil {
-		return err
+	if repo.files == nil {
+		list, err := listFiles(repo.vcs, ".")
+		if err != nil {
+			return err
+		}
+		repo.files = append(make([]string, 0, len(list)), list...)
+		sort.Strings(repo.files)
 	}
-	if err := cmd.Start(); err != nil {
-		return err
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(repo.rdir, path)
+		if err != nil {
+			return err
+		}
+		path = rel
 	}
-	defer cmd.Wait()
-	defer cmd.Process.Kill()
-	s := bufio.NewScanner(out)
-	for s.Scan() {
-		p := s.Text()
+	path = filepath.ToSlash(filepath.Clean(path))
+	files := repo.files
+	if path != "." {
+		files = files[sort.SearchStrings(files, path):]
+	}
+	for _, p := range files {
+		switch {
+		case path == "." || p == path || strings.HasPrefix(p, path+"/"):
+		case strings.HasPrefix(p, path):
+			// siblings like path.ext are sorted before path/
+			continue
+		default:
+			return nil
+		}
 		fi, err := os.Stat(filepath.Join(repo.rdir, p))
 		if err = walk(p, fi, err); err != nil {
 			return err
 		}
 	}
-	return s.Err()
+	return nil
 }
 
 func (repo *Repository) Add(paths ...string) error {
+	repo.files = nil
 	return repo.vcs.Add(paths...)
 }
 
 func (repo *Repository) Command(args ...string) error {
+	repo.files = nil
 	return repo.vcs.Exec(args...)
 }
+
+func (repo *Repository) Status() ([]*Status, error) {
+	list, err := repo.vcs.Status()
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range list {
+		for _, l := range repo.Layers {
+			if len(l.Layers) == 0 {
+				if strings.HasPrefix(st.Path, l.Name+"/") {
+					st.Layer = l.Name
+				}
+				continue
+			}
+			for _, ll := range l.Layers {
+				if p := l.Name + "/" + ll.Name; strings.HasPrefix(st.Path, p+"/") {
+					st.Layer = p
+				}
+			}
+		}
+		if st.Layer != "" {
+			st.Path = st.Path[len(st.Layer)+1:]
+		}
+	}
+	return list, nil
+}
+
+func (repo *Repository) Commit(msg string, paths ...string) error {
+	return repo.vcs.Commit(msg, paths...)
+}
+
+func (repo *Repository) Pull() error {
+	repo.files = nil
+	return repo.vcs.Pull()
+}
+
+func (repo *Repository) Push() error {
+	return repo.vcs.Push()
+}
+
+func (repo *Repository) Log(n int) ([]*LogEntry, error) {
+	return repo.vcs.Log(n)
+}
+
+type Config struct {
+	VCS       string   `json:"vcs,omitempty"`
+	Target    string   `json:"target,omitempty"`
+	LinkStyle string   `json:"linkstyle,omitempty"`
+	Roots     []string `json:"roots,omitempty"`
+}
+
+type Lock struct {
+	Subrepos map[string]string `json:"subrepos,omitempty"`
+}
@@ -1,7 +1,7 @@
 //
 // nazuna :: repository_test.go
 //
-//   Copyright (c) 2013-2022 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -9,6 +9,9 @@
 package nazuna_test
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,10 +20,11 @@ import (
 	"testing"
 
 	"github.com/hattya/nazuna"
+	"github.com/hattya/nazuna/internal/test"
 )
 
 func TestOpen(t *testing.T) {
-	sandbox(t)
+	dir := sandbox(t)
 
 	if err := mkdir(".nzn", "r", ".git"); err != nil {
 		t.Fatal(err)
@@ -39,6 +43,70 @@ func TestOpen(t *testing.T) {
 	if g, e := string(data), "[]\n"; g != e {
 		t.Errorf("expected %q, got %q", e, g)
 	}
+
+	// ambiguous
+	if err := mkdir(".nzn", "r", ".hg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nazuna.Open(nil, "."); err == nil {
+		t.Error("expected error")
+	}
+	repo.Config.VCS = "hg"
+	if err := repo.FlushConfig(); err != nil {
+		t.Fatal(err)
+	}
+	repo, err = nazuna.Open(nil, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := repo.Config.VCS, "hg"; g != e {
+		t.Errorf("Config.VCS = %q, expected %q", g, e)
+	}
+
+	// target
+	for _, target := range []string{
+		"$NAZUNA_TARGET",
+		"wc",
+		filepath.Join(dir, "wc"),
+	} {
+		t.Setenv("NAZUNA_TARGET", "wc")
+		repo.Config.Target = target
+		if err := repo.FlushConfig(); err != nil {
+			t.Fatal(err)
+		}
+		repo, err = nazuna.Open(nil, ".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		wc, err := repo.WC()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g, e := wc.PathFor(".vimrc"), filepath.Join(dir, "wc", ".vimrc"); g != e {
+			t.Errorf("WC.PathFor(%q) = %q, expected %q", ".vimrc", g, e)
+		}
+		if g, e := repo.PathFor(nil, "nazuna.json"), filepath.Join(dir, ".nzn", "r", "nazuna.json"); g != e {
+			t.Errorf("Repository.PathFor(%q) = %q, expected %q", "nazuna.json", g, e)
+		}
+	}
+}
+
+func TestLock(t *testing.T) {
+	repo := init_(t)
+
+	repo.Lock.Subrepos = map[string]string{
+		"github.com/hattya/nazuna": "0123456789abcdef0123456789abcdef01234567",
+	}
+	if err := repo.FlushLock(); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := nazuna.Open(nil, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := repo.Lock.Subrepos["github.com/hattya/nazuna"], "0123456789abcdef0123456789abcdef01234567"; g != e {
+		t.Errorf("expected %q, got %q", e, g)
+	}
 }
 
 func TestOpenError(t *testing.T) {
@@ -71,6 +139,37 @@ func TestOpenError(t *testing.T) {
 	if _, err := nazuna.Open(nil, "."); err == nil {
 		t.Error("expected error")
 	}
+	if err := os.Remove(filepath.Join(".nzn", "r", "nazuna.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mkdir(".nzn", "r", "nazuna.lock"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nazuna.Open(nil, "."); err == nil {
+		t.Error("expected error")
+	}
+	if err := os.Remove(filepath.Join(".nzn", "r", "nazuna.lock")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mkdir(".nzn", "config.json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nazuna.Open(nil, "."); err == nil {
+		t.Error("expected error")
+	}
+	if err := os.Remove(filepath.Join(".nzn", "config.json")); err != nil {
+		t.Fatal(err)
+	}
+	// unknown vcs in config.json
+	if err := os.WriteFile(filepath.Join(".nzn", "config.json"), []byte(`{"vcs": "cvs"}`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	switch _, err := nazuna.Open(nil, "."); {
+	case err == nil:
+		t.Error("expected error")
+	case err.Error() != "unknown vcs 'cvs'":
+		t.Error("unexpected error:", err)
+	}
 }
 
 func TestNewLayer(t *testing.T) {
@@ -154,6 +253,172 @@ func TestRepositoryPaths(t *testing.T) {
 	if g, e := repo.SubrepoFor("subrepo"), filepath.Join(subroot, "subrepo"); g != e {
 		t.Errorf("expected %q, got %q", e, g)
 	}
+	if g, e := repo.SubrepoFor("https://example.com/subrepo.git"), filepath.Join(subroot, "example.com", "subrepo"); g != e {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	if err := nazuna.RegisterRemote(&nazuna.RemoteHandler{
+		Prefix: "src.example.com/",
+		Expr:   `^(?P<root>src\.example\.com/[^/]+/[^/.]+)(?:\.git)?(?P<path>.*)$`,
+		VCS:    "git",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if g, e := repo.SubrepoFor("src.example.com/user/repo.git/path"), filepath.Join(subroot, "src.example.com", "user", "repo", "path"); g != e {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+}
+
+func TestRepositoryNewRemote(t *testing.T) {
+	var n int
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		fmt.Fprintln(w, `<meta name="go-import" content="go.example.com/repo git https://git.example.com/repo">`)
+	}))
+	defer s.Close()
+
+	save := http.DefaultClient
+	defer func() { http.DefaultClient = save }()
+	http.DefaultClient = test.NewHTTPClient(s.Listener.Addr().String())
+
+	repo := init_(t)
+	for range 2 {
+		r, err := repo.NewRemote("go.example.com/repo/vim")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g, e := r.Root, "go.example.com/repo"; g != e {
+			t.Errorf("Remote.Root = %v, expected %v", g, e)
+		}
+		// reopen
+		repo, err = nazuna.Open(nil, repo.Root())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g, e := n, 1; g != e {
+		t.Errorf("expected %v requests, got %v", e, g)
+	}
+	data, err := os.ReadFile(filepath.Join(repo.Root(), ".nzn", "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := string(data), `{
+  "go-import": {
+    "go.example.com/repo": {
+      "vcs": "git",
+      "uri": "https://git.example.com/repo"
+    }
+  }
+}
+`; g != e {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+}
+
+func TestRepositoryNewRemoteOffline(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"scm":"git"}`)
+	}))
+
+	save := http.DefaultClient
+	defer func() { http.DefaultClient = save }()
+	http.DefaultClient = test.NewHTTPClient(s.Listener.Addr().String())
+
+	repo := init_(t)
+	if _, err := repo.NewRemote("bitbucket.org/nazuna/offline"); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	repo, err := nazuna.Open(nil, repo.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := repo.NewRemote("bitbucket.org/nazuna/offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := r.VCS, "git"; g != e {
+		t.Errorf("Remote.VCS = %v, expected %v", g, e)
+	}
+	if g, e := r.URI, "https://bitbucket.org/nazuna/offline.git"; g != e {
+		t.Errorf("Remote.URI = %v, expected %v", g, e)
+	}
+	if _, err := repo.NewRemote("bitbucket.org/nazuna/online"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestSubrepos(t *testing.T) {
+	repo := init_(t)
+
+	a, err := repo.NewLayer("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := repo.NewLayer("b/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.NewSubrepo("github.com/hattya/nazuna", "nazuna"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.NewSubrepo("github.com/hattya/go.cli", "cli"); err != nil {
+		t.Fatal(err)
+	}
+	var list []string
+	for _, sub := range repo.Subrepos() {
+		list = append(list, sub.Src)
+	}
+	if g, e := list, []string{"github.com/hattya/go.cli", "github.com/hattya/nazuna"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %v, got %v", e, g)
+	}
+}
+
+func TestClones(t *testing.T) {
+	repo := init_(t)
+
+	list, err := repo.Clones()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := len(list), 0; g != e {
+		t.Errorf("expected %v, got %v", e, g)
+	}
+
+	for _, p := range []string{
+		filepath.Join("github.com", "hattya", "nazuna", ".git"),
+		filepath.Join("github.com", "hattya", "nazuna", "sub", ".git"),
+		filepath.Join("github.com", "hattya", "go.cli", ".git"),
+		filepath.Join("github.com", "hattya", "_"),
+	} {
+		if err := mkdir(repo.SubrepoFor(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err = repo.Clones()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := list, []string{"github.com/hattya/go.cli", "github.com/hattya/nazuna"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %v, got %v", e, g)
+	}
+
+	if err := repo.RemoveClone("github.com/hattya/nazuna"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(repo.SubrepoFor("github.com/hattya")); err != nil {
+		t.Error("expected to keep parent directories")
+	}
+	if err := os.Remove(repo.SubrepoFor(filepath.Join("github.com", "hattya", "_"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RemoveClone("github.com/hattya/go.cli"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(repo.SubrepoFor("github.com")); err == nil {
+		t.Error("expected to remove parent directories")
+	}
 }
 
 var findPathTests = []struct {
@@ -200,6 +465,125 @@ func TestFindPath(t *testing.T) {
 	}
 }
 
+func TestRepositoryWalk(t *testing.T) {
+	repo := init_(t)
+
+	l, err := repo.NewLayer("layer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	walk := func() (list []string) {
+		t.Helper()
+		err := repo.Walk(repo.PathFor(l, "."), func(path string, _ os.FileInfo, err error) error {
+			list = append(list, path)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	for _, n := range []string{"a", "b"} {
+		if err := touch(repo.PathFor(l, n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Add(filepath.Join("layer", "a")); err != nil {
+		t.Fatal(err)
+	}
+	if g, e := walk(), []string{"layer/a"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	// not invalidated
+	vcs, err := nazuna.VCSFor(new(testUI), repo.PathFor(nil, "."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vcs.Add(filepath.Join("layer", "b")); err != nil {
+		t.Fatal(err)
+	}
+	if g, e := walk(), []string{"layer/a"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	// invalidated
+	if _, err := repo.NewLayer("other"); err != nil {
+		t.Fatal(err)
+	}
+	if g, e := walk(), []string{"layer/a", "layer/b"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	// sorted between layer and layer/
+	if err := touch(repo.PathFor(nil, "layer.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Add("layer.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if g, e := walk(), []string{"layer/a", "layer/b"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+}
+
+func TestRepositoryStatus(t *testing.T) {
+	repo := init_(t)
+
+	for _, n := range []string{"a", "b/1"} {
+		l, err := repo.NewLayer(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := touch(repo.PathFor(l, ".vimrc")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Add("a"); err != nil {
+		t.Fatal(err)
+	}
+	st, err := repo.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []string
+	for _, s := range st {
+		list = append(list, fmt.Sprintf("%c %v:%v", s.Code, s.Layer, s.Path))
+	}
+	if g, e := list, []string{
+		"A a:.vimrc",
+		"? b/1:.vimrc",
+		"? :nazuna.json",
+	}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+
+	for n, v := range map[string]string{
+		"user.name":  "Nazuna",
+		"user.email": "nazuna@example.com",
+	} {
+		if err := repo.Command("config", "--local", n, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Commit("."); err != nil {
+		t.Fatal(err)
+	}
+	log, err := repo.Log(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := len(log), 1; g != e {
+		t.Errorf("len(Repository.Log()) = %v, expected %v", g, e)
+	}
+	if err := repo.Pull(); err == nil {
+		t.Error("expected error")
+	}
+	if err := repo.Push(); err == nil {
+		t.Error("expected error")
+	}
+}
+
 func init_(t *testing.T) *nazuna.Repository {
 	t.Helper()
 
@@ -0,0 +1,113 @@
+//
+// nazuna :: script.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package nazuna
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// reserved directory in layers
+const scriptDir = ".nzn/scripts"
+
+type Script struct {
+	Layer string
+	Name  string
+	Once  bool
+	Hash  string
+
+	path string
+}
+
+func (s *Script) String() string {
+	return s.Layer + ":" + s.Name
+}
+
+type ScriptState struct {
+	Hash string `json:"hash"`
+	Code int    `json:"code"`
+}
+
+func (wc *WC) Scripts() ([]*Script, error) {
+	layers, err := wc.Layers()
+	if err != nil {
+		return nil, err
+	}
+	var list []*Script
+	for _, l := range layers {
+		for _, mode := range []string{"once", "onchange"} {
+			dir := filepath.Join(scriptDir, mode)
+			err := wc.repo.Walk(wc.repo.PathFor(l, dir), func(path string, fi os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				name := path[len(l.Path())+len(scriptDir)+2:]
+				// ignore files in subdirectories
+				if strings.Count(name, "/") != 1 {
+					return nil
+				}
+				s := &Script{
+					Layer: l.Path(),
+					Name:  name,
+					Once:  mode == "once",
+					path:  wc.repo.PathFor(nil, path),
+				}
+				data, err := os.ReadFile(s.path)
+				if err != nil {
+					return err
+				}
+				sum := sha256.Sum256(data)
+				s.Hash = hex.EncodeToString(sum[:])
+				list = append(list, s)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return list, nil
+}
+
+func (wc *WC) ScriptStatus(s *Script) string {
+	st, ok := wc.State.Scripts[s.String()]
+	switch {
+	case !ok:
+		return "pending"
+	case st.Code != 0:
+		return "failed"
+	case !s.Once && st.Hash != s.Hash:
+		return "changed"
+	}
+	return "ok"
+}
+
+func (wc *WC) RunScript(s *Script) error {
+	cmd := exec.Command(s.path)
+	cmd.Dir = wc.repo.root
+	cmd.Env = append(os.Environ(), "NAZUNA_LAYER="+s.Layer)
+	err := wc.ui.Exec(cmd)
+	if wc.State.Scripts == nil {
+		wc.State.Scripts = make(map[string]*ScriptState)
+	}
+	code := exitCode(err)
+	wc.State.Scripts[s.String()] = &ScriptState{
+		Hash: s.Hash,
+		Code: code,
+	}
+	if err != nil {
+		return fmt.Errorf("script '%v' failed: %w", s, err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+//
+// nazuna :: script_test.go
+//
+//   Copyright (c) 2026 Akinori Hattori <hattya@gmail.com>
+//
+//   SPDX-License-Identifier: MIT
+//
+
+package nazuna_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/hattya/nazuna"
+)
+
+func TestScripts(t *testing.T) {
+	init_(t)
+
+	ui := new(testUI)
+	repo, err := nazuna.Open(ui, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := repo.NewLayer("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext := ".sh"
+	if runtime.GOOS == "windows" {
+		ext = ".bat"
+	}
+	script := func(name string, rc int) {
+		t.Helper()
+		var s string
+		if runtime.GOOS == "windows" {
+			s = fmt.Sprintf("@echo %%NAZUNA_LAYER%%\r\n@exit /b %v\r\n", rc)
+		} else {
+			s = fmt.Sprintf("#!/bin/sh\necho $NAZUNA_LAYER\nexit %v\n", rc)
+		}
+		path := repo.PathFor(l, filepath.Join(".nzn", "scripts", filepath.FromSlash(name)+ext))
+		if err := mkdir(filepath.Dir(path)); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(s), 0o777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script("once/1", 0)
+	script("onchange/2", 0)
+	// ignored
+	script("once/dir/3", 0)
+	script("4", 0)
+	for _, p := range []string{".vimrc", ".nzn/vimrc"} {
+		if err := touch(repo.PathFor(l, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Add("."); err != nil {
+		t.Fatal(err)
+	}
+
+	wc, err := repo.WC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wc.MergeLayers(); err != nil {
+		t.Fatal(err)
+	}
+	if g, e := len(wc.State.WC), 2; g != e {
+		t.Fatalf("len(State.WC) = %v, expected %v", g, e)
+	}
+	for i, e := range []string{".nzn/vimrc", ".vimrc"} {
+		if g := wc.State.WC[i].Path; g != e {
+			t.Errorf("Entry.Path = %q, expected %q", g, e)
+		}
+	}
+
+	status := func() []string {
+		t.Helper()
+		list, err := wc.Scripts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var st []string
+		for _, s := range list {
+			st = append(st, fmt.Sprintf("%v %v", wc.ScriptStatus(s), s))
+		}
+		return st
+	}
+	if g, e := status(), []string{
+		"pending a:once/1" + ext,
+		"pending a:onchange/2" + ext,
+	}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	list, err := wc.Scripts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range list {
+		if err := wc.RunScript(s); err != nil {
+			t.Error(err)
+		}
+	}
+	if g, e := status(), []string{
+		"ok a:once/1" + ext,
+		"ok a:onchange/2" + ext,
+	}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+
+	script("once/1", 1)
+	script("onchange/2", 1)
+	if g, e := status(), []string{
+		"ok a:once/1" + ext,
+		"changed a:onchange/2" + ext,
+	}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	list, err = wc.Scripts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wc.RunScript(list[1]); err == nil {
+		t.Error("expected error")
+	}
+	if g, e := wc.State.Scripts[list[1].String()].Code, 1; g != e {
+		t.Errorf("ScriptState.Code = %v, expected %v", g, e)
+	}
+	if g, e := status(), []string{
+		"ok a:once/1" + ext,
+		"failed a:onchange/2" + ext,
+	}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+}
@@ -1,7 +1,7 @@
 //
 // nazuna :: util.go
 //
-//   Copyright (c) 2013-2025 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -12,7 +12,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -36,6 +35,47 @@ func IsEmptyDir(path string) bool {
 	return err == io.EOF
 }
 
+func sameFile(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	return err == nil && os.SameFile(fa, fb)
+}
+
+func copyFile(src, dst string) error {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o777); err != nil {
+		return err
+	}
+	// do not write through the existing symlink
+	if di, err := os.Lstat(dst); err == nil && (fi.Mode()&os.ModeSymlink != 0 || !di.Mode().IsRegular()) {
+		if err := os.Remove(dst); err != nil {
+			return err
+		}
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		r, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(r, dst)
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	// overwrite the existing file to keep hard links
+	if err := os.WriteFile(dst, data, fi.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chmod(dst, fi.Mode().Perm())
+}
+
 func SplitPath(path string) (string, string) {
 	dir, name := filepath.Split(path)
 	dir = strings.TrimRightFunc(dir, func(r rune) bool {
@@ -62,7 +102,7 @@ func sortKeys(i any) []string {
 }
 
 func marshal(repo *Repository, path string, v any) error {
-	rel, err := filepath.Rel(repo.root, path)
+	rel, err := filepath.Rel(filepath.Dir(repo.nzndir), path)
 	if err != nil {
 		return err
 	}
@@ -77,7 +117,7 @@ func marshal(repo *Repository, path string, v any) error {
 }
 
 func unmarshal(repo *Repository, path string, v any) error {
-	rel, err := filepath.Rel(repo.root, path)
+	rel, err := filepath.Rel(filepath.Dir(repo.nzndir), path)
 	if err != nil {
 		return err
 	}
@@ -92,19 +132,3 @@ func unmarshal(repo *Repository, path string, v any) error {
 	}
 	return nil
 }
-
-func httpGet(uri string) ([]byte, error) {
-	resp, err := http.Get(uri)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("%v: %v", uri, resp.Status)
-	}
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return data, nil
-}
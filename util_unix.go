@@ -1,7 +1,7 @@
 //
 // nazuna :: util_unix.go
 //
-//   Copyright (c) 2013-2023 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -11,21 +11,58 @@
 package nazuna
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"syscall"
 )
 
 func IsLink(path string) bool {
 	fi, err := os.Lstat(path)
-	return err == nil && fi.Mode()&os.ModeSymlink != 0
+	if err != nil {
+		return false
+	}
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		return true
+	case fi.Mode().IsRegular():
+		// hard link
+		st, ok := fi.Sys().(*syscall.Stat_t)
+		return ok && st.Nlink > 1
+	}
+	return false
 }
 
 func LinksTo(path, origin string) bool {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		// hard link
+		if !IsLink(path) {
+			return false
+		}
+		oi, err := os.Stat(origin)
+		return err == nil && os.SameFile(fi, oi)
+	}
 	r, err := os.Readlink(path)
 	if err != nil {
 		return false
 	}
-	return filepath.Join(filepath.Dir(path), r) == origin
+	if !filepath.IsAbs(r) {
+		r = filepath.Join(filepath.Dir(path), r)
+	}
+	if filepath.IsAbs(r) != filepath.IsAbs(origin) {
+		if r, err = filepath.Abs(r); err != nil {
+			return false
+		}
+		if origin, err = filepath.Abs(origin); err != nil {
+			return false
+		}
+	}
+	return r == origin
 }
 
 func CreateLink(src, dst string) error {
@@ -36,6 +73,23 @@ func CreateLink(src, dst string) error {
 	return os.Symlink(rel, dst)
 }
 
+func createLink(src, dst, style string) error {
+	switch style {
+	case "absolute":
+		abs, err := filepath.Abs(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(abs, dst)
+	case "hard":
+		// directories cannot be hard linked
+		if !IsDir(src) {
+			return os.Link(src, dst)
+		}
+	}
+	return CreateLink(src, dst)
+}
+
 func Unlink(path string) error {
 	if !IsLink(path) {
 		return &os.PathError{
@@ -46,3 +100,20 @@ func Unlink(path string) error {
 	}
 	return os.Remove(path)
 }
+
+// fileID returns the identifier of the regular file
+func fileID(path string) string {
+	fi, err := os.Lstat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return ""
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%x:%x", st.Dev, st.Ino)
+}
+
+func shell(s string) *exec.Cmd {
+	return exec.Command("sh", "-c", s)
+}
@@ -1,7 +1,7 @@
 //
 // nazuna :: util_unix_test.go
 //
-//   Copyright (c) 2014-2023 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2014-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -11,6 +11,8 @@
 package nazuna_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hattya/nazuna"
@@ -50,3 +52,61 @@ func TestCreateLink(t *testing.T) {
 		t.Error("expected error")
 	}
 }
+
+func TestCreateLinkStyle(t *testing.T) {
+	dir := sandbox(t)
+
+	if err := touch("src"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mkdir("dir"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		style string
+		src   string
+		dst   string
+		link  string
+	}{
+		{"", "src", "rel", "src"},
+		{"absolute", "src", "abs", filepath.Join(dir, "src")},
+		{"hard", "src", "hard", ""},
+		{"hard", "dir", "hard.d", "dir"},
+	} {
+		if err := nazuna.CreateLinkStyle(tt.src, tt.dst, tt.style); err != nil {
+			t.Fatal(err)
+		}
+		if !nazuna.IsLink(tt.dst) {
+			t.Errorf("IsLink(%q) = false, expected true", tt.dst)
+		}
+		if !nazuna.LinksTo(tt.dst, tt.src) {
+			t.Errorf("LinksTo(%q, %q) = false, expected true", tt.dst, tt.src)
+		}
+		if !nazuna.LinksTo(tt.dst, filepath.Join(dir, tt.src)) {
+			t.Errorf("LinksTo(%q, %q) = false, expected true", tt.dst, filepath.Join(dir, tt.src))
+		}
+		if p, o := tt.dst, "_"; nazuna.LinksTo(p, o) {
+			t.Errorf("LinksTo(%q, %q) = true, expected false", p, o)
+		}
+		if r, _ := os.Readlink(tt.dst); r != tt.link {
+			t.Errorf("Readlink(%q) = %q, expected %q", tt.dst, r, tt.link)
+		}
+	}
+	// hard link
+	if !nazuna.IsLink("src") {
+		t.Errorf("IsLink(%q) = false, expected true", "src")
+	}
+	if err := nazuna.Unlink("hard"); err != nil {
+		t.Error(err)
+	}
+	if nazuna.IsLink("src") {
+		t.Errorf("IsLink(%q) = true, expected false", "src")
+	}
+	if err := nazuna.Unlink("src"); err == nil {
+		t.Error("expected error")
+	}
+	if nazuna.IsLink("dir") {
+		t.Errorf("IsLink(%q) = true, expected false", "dir")
+	}
+}
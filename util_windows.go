@@ -1,7 +1,7 @@
 //
 // nazuna :: util_windows.go
 //
-//   Copyright (c) 2013-2021 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -9,9 +9,12 @@
 package nazuna
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
@@ -127,6 +130,11 @@ func CreateLink(src, dst string) error {
 	return os.Link(src, dst)
 }
 
+func createLink(src, dst, _ string) error {
+	// always hard links or junctions
+	return CreateLink(src, dst)
+}
+
 func Unlink(path string) error {
 	if !IsLink(path) {
 		return &os.PathError{
@@ -138,6 +146,24 @@ func Unlink(path string) error {
 	return os.Remove(path)
 }
 
+// fileID returns the identifier of the regular file
+func fileID(path string) string {
+	h, err := createFile(path, 0)
+	if err != nil {
+		return ""
+	}
+	defer windows.CloseHandle(h)
+
+	var fi windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(h, &fi); err != nil {
+		return ""
+	}
+	if fi.FileAttributes&(windows.FILE_ATTRIBUTE_DIRECTORY|windows.FILE_ATTRIBUTE_REPARSE_POINT) != 0 {
+		return ""
+	}
+	return fmt.Sprintf("%x:%x%08x", fi.VolumeSerialNumber, fi.FileIndexHigh, fi.FileIndexLow)
+}
+
 func createFile(path string, access uint32) (windows.Handle, error) {
 	p, err := windows.UTF16PtrFromString(path)
 	if err != nil {
@@ -197,3 +223,10 @@ func createMountPoint(src, dst string) error {
 	}
 	return nil
 }
+
+func shell(s string) *exec.Cmd {
+	cmd := exec.Command("cmd")
+	// pass the command line as is
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd /s /c "` + s + `"`}
+	return cmd
+}
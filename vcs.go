@@ -1,7 +1,7 @@
 //
 // nazuna :: vcs.go
 //
-//   Copyright (c) 2013-2020 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -9,12 +9,16 @@
 package nazuna
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
 type VCS interface {
@@ -27,6 +31,57 @@ type VCS interface {
 	Add(...string) error
 	List(...string) *exec.Cmd
 	Update() error
+
+	Fetch() error
+	Checkout(string) error
+	Revision() (string, error)
+	Status() ([]*Status, error)
+
+	Commit(string, ...string) error
+	Pull() error
+	Push() error
+	Log(int) ([]*LogEntry, error)
+}
+
+// fileLister is implemented by the VCSs which list files without parsing the
+// output of VCS.List
+type fileLister interface {
+	Files(...string) ([]string, error)
+}
+
+func listFiles(vcs VCS, paths ...string) ([]string, error) {
+	if v, ok := vcs.(fileLister); ok {
+		return v.Files(paths...)
+	}
+	cmd := vcs.List(paths...)
+	if cmd == nil {
+		return nil, errors.New("VCS.List not implemented")
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.FieldsFunc(string(out), func(r rune) bool { return r == '\n' || r == '\r' }), nil
+}
+
+func closeVCS(vcs VCS) error {
+	if c, ok := vcs.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+type Status struct {
+	Code  byte
+	Path  string
+	Layer string
+}
+
+type LogEntry struct {
+	ID      string
+	Author  string
+	Date    time.Time
+	Subject string
 }
 
 type BaseVCS struct {
@@ -70,6 +125,75 @@ func (v *BaseVCS) Update() error {
 	return errors.New("VCS.Update not implemented")
 }
 
+func (v *BaseVCS) Fetch() error {
+	return errors.New("VCS.Fetch not implemented")
+}
+
+func (v *BaseVCS) Checkout(string) error {
+	return errors.New("VCS.Checkout not implemented")
+}
+
+func (v *BaseVCS) Revision() (string, error) {
+	return "", errors.New("VCS.Revision not implemented")
+}
+
+func (v *BaseVCS) Status() ([]*Status, error) {
+	return nil, errors.New("VCS.Status not implemented")
+}
+
+func (v *BaseVCS) Commit(string, ...string) error {
+	return errors.New("VCS.Commit not implemented")
+}
+
+func (v *BaseVCS) Pull() error {
+	return errors.New("VCS.Pull not implemented")
+}
+
+func (v *BaseVCS) Push() error {
+	return errors.New("VCS.Push not implemented")
+}
+
+func (v *BaseVCS) Log(int) ([]*LogEntry, error) {
+	return nil, errors.New("VCS.Log not implemented")
+}
+
+func (v *BaseVCS) log(out string) ([]*LogEntry, error) {
+	var list []*LogEntry
+	for _, s := range strings.Split(out, "\n") {
+		f := strings.Split(s, "\x1f")
+		if len(f) != 4 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, f[2])
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", v.Cmd, err)
+		}
+		list = append(list, &LogEntry{
+			ID:      f[0],
+			Author:  f[1],
+			Date:    t,
+			Subject: f[3],
+		})
+	}
+	return list, nil
+}
+
+func (v *BaseVCS) lines(args ...string) ([]string, error) {
+	out, err := v.output(args...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.FieldsFunc(out, func(r rune) bool { return r == '\n' }), nil
+}
+
+func (v *BaseVCS) output(args ...string) (string, error) {
+	out, err := v.Command(args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%v: %v", v.Cmd, err)
+	}
+	return string(out), nil
+}
+
 type Git struct {
 	BaseVCS
 }
@@ -99,19 +223,136 @@ func (v *Git) List(paths ...string) *exec.Cmd {
 	return v.Command(append([]string{"ls-files"}, paths...)...)
 }
 
+func (v *Git) Files(paths ...string) ([]string, error) {
+	if nativeIndex {
+		if list, err := readIndex(v.Dir, paths...); err == nil {
+			return list, nil
+		}
+	}
+	out, err := v.output(append([]string{"ls-files", "-z"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.FieldsFunc(out, func(r rune) bool { return r == 0 }), nil
+}
+
 func (v *Git) Update() error {
+	// reattach to the default branch after Checkout
+	if _, err := v.output("symbolic-ref", "-q", "HEAD"); err != nil {
+		ref, err := v.output("symbolic-ref", "-q", "--short", "refs/remotes/origin/HEAD")
+		if err != nil {
+			return err
+		}
+		if err := v.Fetch(); err != nil {
+			return err
+		}
+		if err := v.Exec("checkout", "-q", strings.TrimPrefix(strings.TrimSpace(ref), "origin/")); err != nil {
+			return err
+		}
+	}
 	if err := v.Exec("pull"); err != nil {
 		return err
 	}
 	return v.Exec("submodule", "update", "--init", "--recursive")
 }
 
+func (v *Git) Fetch() error {
+	return v.Exec("fetch", "-q", "--tags")
+}
+
+func (v *Git) Checkout(rev string) error {
+	// prefer the remote-tracking branch to follow upstream
+	if _, err := v.output("rev-parse", "-q", "--verify", "refs/remotes/origin/"+rev); err == nil {
+		rev = "origin/" + rev
+	}
+	if err := v.Exec("checkout", "-q", "--detach", rev); err != nil {
+		return err
+	}
+	return v.Exec("submodule", "update", "--init", "--recursive")
+}
+
+func (v *Git) Revision() (string, error) {
+	out, err := v.output("rev-parse", "HEAD")
+	return strings.TrimSpace(out), err
+}
+
+func (v *Git) Status() ([]*Status, error) {
+	out, err := v.output("status", "--porcelain", "-z", "-uall")
+	if err != nil {
+		return nil, err
+	}
+	var list []*Status
+	l := strings.Split(out, "\x00")
+	for i := 0; i < len(l); i++ {
+		if len(l[i]) < 4 {
+			continue
+		}
+		st := &Status{Path: l[i][3:]}
+		switch xy := l[i][:2]; {
+		case xy == "??":
+			st.Code = '?'
+		case xy == "AA" || xy == "DD" || strings.ContainsRune(xy, 'U'):
+			st.Code = 'U'
+		case xy[0] == 'R' || xy[0] == 'C':
+			st.Code = 'A'
+			// skip the original path
+			i++
+		case xy[0] == 'A':
+			st.Code = 'A'
+		case xy[0] == 'D':
+			st.Code = 'R'
+		case xy[1] == 'D':
+			st.Code = '!'
+		default:
+			st.Code = 'M'
+		}
+		list = append(list, st)
+	}
+	return list, nil
+}
+
+func (v *Git) Commit(msg string, paths ...string) error {
+	args := []string{"commit", "-q", "-m", msg}
+	if len(paths) == 0 {
+		args = append(args, "-a")
+	} else {
+		args = append(append(args, "--"), paths...)
+	}
+	return v.Exec(args...)
+}
+
+func (v *Git) Pull() error {
+	if err := v.Exec("pull", "-q"); err != nil {
+		return err
+	}
+	return v.Exec("submodule", "update", "--init", "--recursive")
+}
+
+func (v *Git) Push() error {
+	return v.Exec("push", "-q")
+}
+
+func (v *Git) Log(n int) ([]*LogEntry, error) {
+	args := []string{"log", "--format=%H%x1f%an <%ae>%x1f%aI%x1f%s"}
+	if n > 0 {
+		args = append(args, fmt.Sprintf("-%d", n))
+	}
+	out, err := v.output(args...)
+	if err != nil {
+		return nil, err
+	}
+	return v.log(out)
+}
+
 type Mercurial struct {
 	BaseVCS
+
+	srv   *hgServer
+	nosrv bool
 }
 
 func newMercurial(ui UI, dir string) VCS {
-	return &Mercurial{BaseVCS{
+	return &Mercurial{BaseVCS: BaseVCS{
 		Name: "Mercurial",
 		Cmd:  "hg",
 		UI:   ui,
@@ -119,6 +360,56 @@ func newMercurial(ui UI, dir string) VCS {
 	}}
 }
 
+// the command server is used only for the non-interactive queries
+func (v *Mercurial) output(args ...string) (string, error) {
+	if srv := v.server(); srv != nil {
+		var stdout, stderr bytes.Buffer
+		rc, err := srv.Run(&stdout, &stderr, args...)
+		var se sendError
+		switch {
+		case errors.As(err, &se):
+			// not run yet
+			v.fallback()
+		case err != nil:
+			// do not run it again
+			v.fallback()
+			return "", fmt.Errorf("%v: cmdserver: %w", v.Cmd, err)
+		case rc != 0:
+			return "", fmt.Errorf("%v: %v", v.Cmd, exitError(rc))
+		default:
+			return stdout.String(), nil
+		}
+	}
+	return v.BaseVCS.output(args...)
+}
+
+func (v *Mercurial) server() *hgServer {
+	if v.srv == nil && cmdServer && !v.nosrv && IsDir(filepath.Join(v.Dir, ".hg")) {
+		srv, err := startHgServer(v.Cmd, v.Dir)
+		if err != nil {
+			// fall back to exec
+			v.nosrv = true
+			return nil
+		}
+		v.srv = srv
+	}
+	return v.srv
+}
+
+func (v *Mercurial) fallback() {
+	v.Close()
+	v.nosrv = true
+}
+
+func (v *Mercurial) Close() error {
+	if v.srv == nil {
+		return nil
+	}
+	err := v.srv.Close()
+	v.srv = nil
+	return err
+}
+
 func (v *Mercurial) Init(dir string) error {
 	return v.Exec("init", dir)
 }
@@ -135,6 +426,14 @@ func (v *Mercurial) List(paths ...string) *exec.Cmd {
 	return v.Command(append([]string{"status", "-madcn", "--config", "ui.slash=True"}, paths...)...)
 }
 
+func (v *Mercurial) Files(paths ...string) ([]string, error) {
+	out, err := v.output(append([]string{"status", "-madcn", "--config", "ui.slash=True"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.FieldsFunc(out, func(r rune) bool { return r == '\n' }), nil
+}
+
 func (v *Mercurial) Update() error {
 	if err := v.Exec("pull"); err != nil {
 		return err
@@ -142,6 +441,87 @@ func (v *Mercurial) Update() error {
 	return v.Exec("update")
 }
 
+func (v *Mercurial) Fetch() error {
+	return v.Exec("pull", "-q")
+}
+
+func (v *Mercurial) Checkout(rev string) error {
+	return v.Exec("update", "-q", "-r", rev)
+}
+
+func (v *Mercurial) Revision() (string, error) {
+	out, err := v.output("log", "-r", ".", "--template", "{node}")
+	return strings.TrimSpace(out), err
+}
+
+func (v *Mercurial) Status() ([]*Status, error) {
+	out, err := v.output("status", "-0", "--config", "ui.slash=True")
+	if err != nil {
+		return nil, err
+	}
+	var list []*Status
+	m := make(map[string]*Status)
+	for _, s := range strings.Split(out, "\x00") {
+		if len(s) < 3 {
+			continue
+		}
+		st := &Status{
+			Code: s[0],
+			Path: s[2:],
+		}
+		list = append(list, st)
+		m[st.Path] = st
+	}
+	// unresolved files
+	out, err = v.output("resolve", "-l", "--config", "ui.slash=True")
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range strings.Split(out, "\n") {
+		if len(s) < 3 || s[0] != 'U' {
+			continue
+		}
+		if st, ok := m[s[2:]]; ok {
+			st.Code = 'U'
+		} else {
+			list = append(list, &Status{
+				Code: 'U',
+				Path: s[2:],
+			})
+		}
+	}
+	return list, nil
+}
+
+func (v *Mercurial) Commit(msg string, paths ...string) error {
+	return v.Exec(append([]string{"commit", "-q", "-m", msg}, paths...)...)
+}
+
+func (v *Mercurial) Pull() error {
+	return v.Exec("pull", "-q", "-u")
+}
+
+func (v *Mercurial) Push() error {
+	err := v.Exec("push", "-q")
+	// no outgoing changes
+	if exitCode(err) == 1 {
+		return nil
+	}
+	return err
+}
+
+func (v *Mercurial) Log(n int) ([]*LogEntry, error) {
+	args := []string{"log", "--template", "{node}\x1f{author}\x1f{date|rfc3339date}\x1f{desc|firstline}\n"}
+	if n > 0 {
+		args = append(args, "-l", fmt.Sprint(n))
+	}
+	out, err := v.output(args...)
+	if err != nil {
+		return nil, err
+	}
+	return v.log(out)
+}
+
 var (
 	mu    sync.RWMutex
 	vcses = map[string]*vcsType{
@@ -173,18 +553,89 @@ func FindVCS(ui UI, cmd, dir string) (VCS, error) {
 	if v, ok := vcses[k]; ok {
 		return v.new(ui, dir), nil
 	}
+	switch vcs, err := findPlugin(ui, k, dir); {
+	case err != nil:
+		return nil, err
+	case vcs != nil:
+		return vcs, nil
+	}
 	return nil, fmt.Errorf("unknown vcs '%v'", cmd)
 }
 
+func vcsOf(dir string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if list := candidates(dir); len(list) > 0 {
+		return list[0]
+	}
+	k, _ := pluginFor(nil, dir)
+	return k
+}
+
 func VCSFor(ui UI, dir string) (VCS, error) {
 	mu.RLock()
 	defer mu.RUnlock()
 
-	for _, v := range vcses {
-		if IsDir(filepath.Join(dir, v.ctrlDir)) {
-			vcs := v.new(ui, dir)
-			return vcs, nil
-		}
+	switch list := candidates(dir); len(list) {
+	case 0:
+	case 1:
+		return vcses[list[0]].new(ui, dir), nil
+	default:
+		return nil, fmt.Errorf("ambiguous vcs for directory '%v' (%v)", dir, strings.Join(list, ", "))
+	}
+	if _, vcs := pluginFor(ui, dir); vcs != nil {
+		return vcs, nil
 	}
 	return nil, fmt.Errorf("unknown vcs for directory '%v'", dir)
 }
+
+func candidates(dir string) []string {
+	var list []string
+	for _, k := range sortKeys(vcses) {
+		if vcses[k].detect(dir) {
+			list = append(list, k)
+		}
+	}
+	// bare repository
+	if len(list) == 0 && IsDir(filepath.Join(dir, "objects")) && IsDir(filepath.Join(dir, "refs")) {
+		list = append(list, "git")
+	}
+	return list
+}
+
+func (t *vcsType) detect(dir string) bool {
+	fi, err := os.Stat(filepath.Join(dir, t.ctrlDir))
+	switch {
+	case err != nil:
+		return false
+	case fi.IsDir():
+		return true
+	}
+	// worktree or submodule
+	return t.ctrlDir == ".git" && gitDir(dir) != ""
+}
+
+func gitDir(dir string) string {
+	p := filepath.Join(dir, ".git")
+	if IsDir(p) {
+		return p
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return ""
+	}
+	l, _, _ := strings.Cut(string(data), "\n")
+	p, ok := strings.CutPrefix(strings.TrimSpace(l), "gitdir:")
+	if !ok {
+		return ""
+	}
+	p = filepath.FromSlash(strings.TrimSpace(p))
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	if !IsDir(p) {
+		return ""
+	}
+	return p
+}
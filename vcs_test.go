@@ -1,7 +1,7 @@
 //
 // nazuna :: vcs_test.go
 //
-//   Copyright (c) 2013-2022 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -11,6 +11,8 @@ package nazuna_test
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/hattya/nazuna"
@@ -93,12 +95,68 @@ func TestVCSFor(t *testing.T) {
 		t.Fatalf("expected *Git, got %T", vcs)
 	}
 
+	// ambiguous
+	if err := mkdir(".hg"); err != nil {
+		t.Fatal(err)
+	}
+	switch _, err := nazuna.VCSFor(nil, dir); {
+	case err == nil:
+		t.Error("expected error")
+	case !strings.HasSuffix(err.Error(), "(git, hg)"):
+		t.Error("unexpected error:", err)
+	}
+	if err := os.Remove(".hg"); err != nil {
+		t.Fatal(err)
+	}
+
 	if err := os.Remove(".git"); err != nil {
 		t.Fatal(err)
 	}
 	if _, err = nazuna.VCSFor(nil, dir); err == nil {
 		t.Error("expected error")
 	}
+
+	// worktree
+	if err := mkdir("gitdir"); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"gitdir: gitdir\n", "gitdir: " + filepath.ToSlash(filepath.Join(dir, "gitdir"))} {
+		if err := os.WriteFile(".git", []byte(s), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		vcs, err := nazuna.VCSFor(nil, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := vcs.(*nazuna.Git); !ok {
+			t.Errorf("expected *Git, got %T", vcs)
+		}
+	}
+	for _, s := range []string{"gitdir: _\n", "_"} {
+		if err := os.WriteFile(".git", []byte(s), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = nazuna.VCSFor(nil, dir); err == nil {
+			t.Error("expected error")
+		}
+	}
+	if err := os.Remove(".git"); err != nil {
+		t.Fatal(err)
+	}
+
+	// bare repository
+	for _, s := range []string{"objects", "refs"} {
+		if err := mkdir(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vcs, err = nazuna.VCSFor(nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vcs.(*nazuna.Git); !ok {
+		t.Errorf("expected *Git, got %T", vcs)
+	}
 }
 
 func TestBaseVCS(t *testing.T) {
@@ -122,16 +180,44 @@ func TestBaseVCS(t *testing.T) {
 	if cmd := vcs.List("a", "b", "c"); cmd != nil {
 		t.Errorf("expected nil, got %T", cmd)
 	}
+	if _, err := nazuna.ListFiles(vcs, "a", "b", "c"); err == nil {
+		t.Error("expected error")
+	}
 	if err := vcs.Update(); err == nil {
 		t.Error("expected error")
 	}
+	if err := vcs.Fetch(); err == nil {
+		t.Error("expected error")
+	}
+	if err := vcs.Checkout("rev"); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := vcs.Revision(); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := vcs.Status(); err == nil {
+		t.Error("expected error")
+	}
+	if err := vcs.Commit("msg"); err == nil {
+		t.Error("expected error")
+	}
+	if err := vcs.Pull(); err == nil {
+		t.Error("expected error")
+	}
+	if err := vcs.Push(); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := vcs.Log(0); err == nil {
+		t.Error("expected error")
+	}
 }
 
 func TestGitVCS(t *testing.T) {
 	testVCSImpl(t, "git", func(vcs nazuna.VCS) (err error) {
 		for n, v := range map[string]string{
-			"user.name":  "Nazuna",
-			"user.email": "nazuna@example.com",
+			"user.name":                 "Nazuna",
+			"user.email":                "nazuna@example.com",
+			"receive.denyCurrentBranch": "updateInstead",
 		} {
 			if err = vcs.Exec("config", "--local", n, v); err != nil {
 				break
@@ -144,8 +230,13 @@ func TestGitVCS(t *testing.T) {
 func TestMercurialVCS(t *testing.T) {
 	testVCSImpl(t, "hg", func(vcs nazuna.VCS) error {
 		dir := vcs.(*nazuna.Mercurial).Dir
-		data := "[ui]\nusername = Nazuna <nazuna@example.com>\n"
-		return os.WriteFile(filepath.Join(dir, ".hg", "hgrc"), []byte(data), 0o666)
+		f, err := os.OpenFile(filepath.Join(dir, ".hg", "hgrc"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o666)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = f.WriteString("[ui]\nusername = Nazuna <nazuna@example.com>\n")
+		return err
 	})
 }
 
@@ -194,21 +285,146 @@ func testVCSImpl(t *testing.T, cmd string, config func(nazuna.VCS) error) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	ui.Reset()
-	if err := ui.Exec(vcs.List(".")); err != nil {
+	list, err := nazuna.ListFiles(vcs, ".")
+	if err != nil {
 		t.Fatal(err)
 	}
-	if g, e := ui.String(), ""; g != e {
-		t.Errorf("expected %q, got %q", e, g)
+	if g, e := len(list), 0; g != e {
+		t.Errorf("len(ListFiles()) = %v, expected %v", g, e)
 	}
 	if err := vcs.Update(); err != nil {
 		t.Fatal(err)
 	}
-	ui.Reset()
-	if err := ui.Exec(vcs.List(".")); err != nil {
+	list, err = nazuna.ListFiles(vcs, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := list, []string{"dir/file", "file"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	out, err := vcs.List(".").Output()
+	if err != nil {
 		t.Fatal(err)
 	}
-	if g, e := ui.String(), "dir/file\nfile\n"; g != e {
+	if g, e := string(out), "dir/file\nfile\n"; g != e {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+
+	// pull
+	origin := vcs
+	vcs, err = nazuna.FindVCS(ui, cmd, "repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("repo", "file"), []byte("pull\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := vcs.Commit("pull"); err != nil {
+		t.Fatal(err)
+	}
+	if err := origin.Pull(); err != nil {
+		t.Log(ui.String())
+		t.Fatal(err)
+	}
+	vcs = origin
+	log, err := vcs.Log(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := len(log), 2; g != e {
+		t.Fatalf("len(VCS.Log()) = %v, expected %v", g, e)
+	}
+	if g, e := log[0].Subject, "pull"; g != e {
+		t.Errorf("LogEntry.Subject = %q, expected %q", g, e)
+	}
+	if g, e := log[0].Author, "Nazuna <nazuna@example.com>"; g != e {
+		t.Errorf("LogEntry.Author = %q, expected %q", g, e)
+	}
+	if log[0].Date.IsZero() {
+		t.Error("expected LogEntry.Date")
+	}
+	// push
+	if err := config(vcs); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("wc", "file"), []byte("push\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := vcs.Commit("push", "file"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vcs.Push(); err != nil {
+		t.Log(ui.String())
+		t.Fatal(err)
+	}
+	if err := vcs.Push(); err != nil {
+		t.Error(err)
+	}
+	log, err = vcs.Log(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := len(log), 1; g != e {
+		t.Fatalf("len(VCS.Log()) = %v, expected %v", g, e)
+	}
+	if origin, err = nazuna.FindVCS(ui, cmd, "repo"); err != nil {
+		t.Fatal(err)
+	}
+	if rev, err := origin.Log(1); err != nil {
+		t.Error(err)
+	} else if g, e := rev[0].ID, log[0].ID; g != e {
+		t.Errorf("expected %v, got %v", e, g)
+	}
+	if err := vcs.Commit("empty"); err == nil {
+		t.Error("expected error")
+	}
+
+	rev, err := vcs.Revision()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := len(rev), 40; g != e {
+		t.Errorf("len(VCS.Revision()) = %v, expected %v", g, e)
+	}
+	if err := vcs.Fetch(); err != nil {
+		t.Error(err)
+	}
+	if err := vcs.Checkout(rev); err != nil {
+		t.Error(err)
+	}
+	if err := vcs.Checkout("_"); err == nil {
+		t.Error("expected error")
+	}
+
+	if err := os.WriteFile(filepath.Join("wc", "file"), []byte("file\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join("wc", "dir", "file")); err != nil {
+		t.Fatal(err)
+	}
+	if err := touch("wc", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := touch("wc", "added"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vcs.Add("added"); err != nil {
+		t.Fatal(err)
+	}
+	st, err := vcs.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string]byte)
+	for _, s := range st {
+		m[s.Path] = s.Code
+	}
+	if g, e := m, map[string]byte{
+		"added":    'A',
+		"dir/file": '!',
+		"file":     'M',
+		"new":      '?',
+	}; !reflect.DeepEqual(g, e) {
 		t.Errorf("expected %q, got %q", e, g)
 	}
 }
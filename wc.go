@@ -1,7 +1,7 @@
 //
 // nazuna :: wc.go
 //
-//   Copyright (c) 2013-2021 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -11,7 +11,9 @@ package nazuna
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -24,14 +26,17 @@ var (
 type WC struct {
 	State State
 
-	ui   UI
-	repo *Repository
+	ui     UI
+	repo   *Repository
+	styles map[string]string
+	inodes map[string]string
 }
 
 func openWC(repo *Repository) (*WC, error) {
 	wc := &WC{
-		ui:   repo.ui,
-		repo: repo,
+		ui:     repo.ui,
+		repo:   repo,
+		inodes: make(map[string]string),
 	}
 	if err := unmarshal(repo, filepath.Join(repo.nzndir, "state.json"), &wc.State); err != nil {
 		return nil, err
@@ -39,17 +44,61 @@ func openWC(repo *Repository) (*WC, error) {
 	if wc.State.WC == nil {
 		wc.State.WC = []*Entry{}
 	}
+	for _, e := range wc.State.WC {
+		if e.Inode != "" {
+			wc.inodes[e.Path] = e.Inode
+		}
+	}
 	return wc, nil
 }
 
 func (wc *WC) Flush() error {
+	for _, e := range wc.State.WC {
+		if e.Style == "hard" {
+			e.Inode = wc.inodes[e.Path]
+		} else {
+			e.Inode = ""
+		}
+	}
 	return marshal(wc.repo, filepath.Join(wc.repo.nzndir, "state.json"), &wc.State)
 }
 
 func (wc *WC) PathFor(path string) string {
+	if wc.rootOf(path) != "" {
+		return wc.repo.destPath(filepath.FromSlash(path))
+	}
 	return filepath.Join(wc.repo.root, path)
 }
 
+// roots returns the allowed roots outside of the working copy
+func (wc *WC) roots() []string {
+	var list []string
+	for _, r := range wc.repo.Config.Roots {
+		r = filepath.Clean(os.ExpandEnv(r))
+		if filepath.IsAbs(r) && filepath.Dir(r) != r {
+			list = append(list, filepath.ToSlash(r))
+		}
+	}
+	return list
+}
+
+func (wc *WC) rootOf(path string) string {
+	var root string
+	for _, r := range wc.roots() {
+		if strings.HasPrefix(path, r+"/") && len(r) > len(root) {
+			root = r
+		}
+	}
+	return root
+}
+
+func (wc *WC) baseOf(path string) string {
+	if r := wc.rootOf(path); r != "" {
+		return wc.repo.destPath(filepath.FromSlash(r))
+	}
+	return wc.repo.root
+}
+
 func (wc *WC) Rel(base rune, path string) (string, error) {
 	if strings.HasPrefix(path, "$") {
 		return filepath.ToSlash(path), nil
@@ -73,6 +122,10 @@ func (wc *WC) Rel(base rune, path string) (string, error) {
 	}
 	rel, err := filepath.Rel(wc.repo.root, abs)
 	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		// absolute path under the allowed roots
+		if abs = filepath.ToSlash(filepath.Clean(abs)); wc.rootOf(abs) != "" {
+			return abs, nil
+		}
 		return "", fmt.Errorf("'%v' is not under root", path)
 	}
 	return filepath.ToSlash(rel), nil
@@ -84,16 +137,33 @@ func (wc *WC) Exists(path string) bool {
 }
 
 func (wc *WC) IsLink(path string) bool {
-	return IsLink(wc.PathFor(path))
+	return IsLink(wc.PathFor(path)) || wc.isHardLink(path)
+}
+
+// isHardLink reports whether the path is a hard link which is created by Link,
+// even if the file in the repository was replaced
+func (wc *WC) isHardLink(path string) bool {
+	ino, ok := wc.inodes[path]
+	return ok && ino == fileID(wc.PathFor(path))
 }
 
 func (wc *WC) LinksTo(path, origin string) bool {
-	return LinksTo(wc.PathFor(path), origin)
+	path = wc.PathFor(path)
+	if LinksTo(path, origin) {
+		return true
+	}
+	// absolute link in the alternate root
+	o := wc.repo.imagePath(origin)
+	return o != origin && LinksTo(path, o)
 }
 
-func (wc *WC) Link(src, dst string) error {
-	dst = wc.PathFor(dst)
-	for p := filepath.Dir(dst); p != wc.repo.root; p = filepath.Dir(p) {
+func (wc *WC) Link(src, path string) error {
+	base := wc.baseOf(path)
+	style := wc.style(path)
+	// stale hard link
+	stale := wc.isHardLink(path) && !sameFile(wc.PathFor(path), src)
+	dst := wc.PathFor(path)
+	for p := filepath.Dir(dst); p != base; p = filepath.Dir(p) {
 		if IsLink(p) {
 			return &os.PathError{
 				Op:   "link",
@@ -108,15 +178,53 @@ func (wc *WC) Link(src, dst string) error {
 			return err
 		}
 	}
-	return CreateLink(src, dst)
+	if stale {
+		if err := os.Remove(dst); err != nil {
+			return err
+		}
+	}
+	if style == "absolute" {
+		src = wc.repo.imagePath(src)
+	}
+	if err := createLink(src, dst, style); err != nil {
+		return err
+	}
+	if style == "hard" {
+		if ino := fileID(dst); ino != "" {
+			wc.inodes[path] = ino
+		}
+	}
+	return nil
+}
+
+func (wc *WC) style(path string) string {
+	if wc.styles == nil {
+		wc.styles = make(map[string]string)
+		for _, e := range wc.State.WC {
+			wc.styles[e.Path] = e.Style
+		}
+	}
+	if style, ok := wc.styles[path]; ok {
+		return style
+	}
+	if wc.repo.Config.LinkStyle == "relative" {
+		return ""
+	}
+	return wc.repo.Config.LinkStyle
 }
 
 func (wc *WC) Unlink(path string) error {
+	base := wc.baseOf(path)
+	hard := wc.isHardLink(path)
 	path = wc.PathFor(path)
-	if err := Unlink(path); err != nil {
+	if hard {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	} else if err := Unlink(path); err != nil {
 		return err
 	}
-	for p := filepath.Dir(path); p != wc.repo.root; p = filepath.Dir(p) {
+	for p := filepath.Dir(path); p != base; p = filepath.Dir(p) {
 		if IsLink(p) || !IsEmptyDir(p) {
 			break
 		}
@@ -193,7 +301,26 @@ func (wc *WC) MergeLayers() ([]*Entry, error) {
 		return nil, err
 	}
 
+	// directories which contain no-fold directories cannot be folded
+	nofold := make(map[string]bool)
+	for _, p := range sortKeys(b.WC) {
+		for _, e := range b.WC[p] {
+			if !e.IsDir || e.Type != "" {
+				continue
+			}
+			switch fold, err := b.layers[e.Layer].Folds(p); {
+			case err != nil:
+				return nil, fmt.Errorf("layer '%v': %w", e.Layer, err)
+			case !fold:
+				for d := p; d != "." && !nofold[d]; d = path.Dir(d) {
+					nofold[d] = true
+				}
+			}
+		}
+	}
+
 	wc.State.WC = wc.State.WC[:0]
+	wc.styles = nil
 	dir := ""
 	for _, p := range sortKeys(b.WC) {
 		switch {
@@ -203,6 +330,14 @@ func (wc *WC) MergeLayers() ([]*Entry, error) {
 			if e.Type == unlinkable {
 				continue
 			}
+			if e.IsDir && e.Type == "" && nofold[p] {
+				continue
+			}
+			style, err := b.style(e)
+			if err != nil {
+				return nil, err
+			}
+			e.Style = style
 			wc.State.WC = append(wc.State.WC, e)
 			if e.IsDir {
 				dir = p + "/"
@@ -210,7 +345,7 @@ func (wc *WC) MergeLayers() ([]*Entry, error) {
 				dir = ""
 			}
 			if c, ok := b.State[p]; ok {
-				if c.Layer == e.Layer && c.IsDir == e.IsDir {
+				if c.Layer == e.Layer && c.IsDir == e.IsDir && c.Style == e.Style {
 					delete(b.State, p)
 				}
 			}
@@ -224,6 +359,44 @@ func (wc *WC) MergeLayers() ([]*Entry, error) {
 	return ul, nil
 }
 
+func (wc *WC) Untracked(e *Entry) ([]string, error) {
+	if !e.IsDir || e.Type != "" {
+		return nil, nil
+	}
+	origin := e.Path
+	if e.Origin != "" {
+		origin = e.Origin
+	}
+	dir := path.Join(e.Layer, origin)
+	if !IsDir(wc.repo.PathFor(nil, dir)) {
+		return nil, nil
+	}
+	tracked := make(map[string]bool)
+	err := wc.repo.Walk(dir, func(p string, _ os.FileInfo, _ error) error {
+		tracked[p] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// files which were written through the link
+	var list []string
+	err = filepath.WalkDir(wc.repo.PathFor(nil, dir), func(p string, de fs.DirEntry, err error) error {
+		if err != nil || de.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(wc.repo.rdir, p)
+		if err != nil {
+			return err
+		}
+		if rel = filepath.ToSlash(rel); !tracked[rel] {
+			list = append(list, path.Join(e.Path, rel[len(dir)+1:]))
+		}
+		return nil
+	})
+	return list, err
+}
+
 func (wc *WC) Errorf(err error) error {
 	switch v := err.(type) {
 	case *os.LinkError:
@@ -240,9 +413,65 @@ func (wc *WC) Errorf(err error) error {
 	return err
 }
 
+func (wc *WC) RunHooks(event string, paths map[string][]string) error {
+	layers, err := wc.Layers()
+	if err != nil {
+		return err
+	}
+	for _, l := range layers {
+		env := []string{
+			"NAZUNA_HOOK=" + event,
+			"NAZUNA_LAYER=" + l.Path(),
+		}
+		if paths != nil {
+			// only the layers which have changed paths
+			if len(paths[l.Path()]) == 0 {
+				continue
+			}
+			list := make([]string, len(paths[l.Path()]))
+			for i, p := range paths[l.Path()] {
+				list[i] = filepath.FromSlash(p)
+			}
+			env = append(env, "NAZUNA_PATHS="+strings.Join(list, string(os.PathListSeparator)))
+		}
+		var hooks []string
+		if l.abst != nil {
+			hooks = append(hooks, l.abst.Hooks[event]...)
+		}
+		for _, s := range append(hooks, l.Hooks[event]...) {
+			cmd := shell(s)
+			cmd.Dir = wc.repo.root
+			cmd.Env = append(os.Environ(), env...)
+			if err := wc.ui.Exec(cmd); err != nil {
+				return &HookError{
+					Event: event,
+					Layer: l.Path(),
+					Err:   err,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type HookError struct {
+	Event string
+	Layer string
+	Err   error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%v hook of layer '%v' failed: %v", e.Event, e.Layer, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
 type State struct {
-	Layers map[string]string `json:"layers,omitempty"`
-	WC     []*Entry          `json:"wc,omitempty"`
+	Layers  map[string]string       `json:"layers,omitempty"`
+	WC      []*Entry                `json:"wc,omitempty"`
+	Scripts map[string]*ScriptState `json:"scripts,omitempty"`
 }
 
 type Entry struct {
@@ -251,6 +480,8 @@ type Entry struct {
 	Origin string `json:"origin,omitempty"`
 	IsDir  bool   `json:"dir,omitempty"`
 	Type   string `json:"type,omitempty"`
+	Style  string `json:"style,omitempty"`
+	Inode  string `json:"inode,omitempty"`
 }
 
 func (e *Entry) Format(format string) string {
@@ -293,11 +524,13 @@ type wcBuilder struct {
 	State map[string]*Entry
 	WC    map[string][]*Entry
 
-	ui      UI
-	wc      *WC
-	l       *Layer
-	layer   string
-	aliases map[string]string
+	ui       UI
+	wc       *WC
+	l        *Layer
+	layer    string
+	layers   map[string]*Layer
+	aliases  map[string]string
+	foldable map[string]bool
 }
 
 func (b *wcBuilder) build() error {
@@ -310,10 +543,13 @@ func (b *wcBuilder) build() error {
 		b.State[e.Path] = e
 	}
 	b.WC = make(map[string][]*Entry)
+	b.layers = make(map[string]*Layer)
 	b.aliases = make(map[string]string)
+	b.foldable = make(map[string]bool)
 	for _, l := range layers {
 		b.l = l
 		b.layer = l.Path()
+		b.layers[b.layer] = l
 		if err := b.repo(); err != nil {
 			return err
 		}
@@ -338,12 +574,17 @@ func (b *wcBuilder) repo() error {
 			return err
 		}
 		origin := path[len(b.layer)+1:]
+		// setup scripts
+		if strings.HasPrefix(origin, scriptDir+"/") {
+			return nil
+		}
 		path, err = b.alias(origin)
 		if err != nil {
 			return err
 		}
 		if _, ok := b.WC[path]; !ok {
-			b.parents(path, true)
+			// .nzn is not linked as a whole to hide setup scripts
+			b.parents(path, !strings.HasPrefix(origin, ".nzn/"))
 			e := &Entry{
 				Layer: b.layer,
 				Path:  path,
@@ -352,7 +593,8 @@ func (b *wcBuilder) repo() error {
 			b.WC[path] = append(b.WC[path], e)
 			if path != origin {
 				e.Origin = origin
-				for p, o := filepath.Dir(path), filepath.Dir(origin); p != "."; p = filepath.Dir(p) {
+				top := b.wc.rootOf(path)
+				for p, o := filepath.Dir(path), filepath.Dir(origin); p != "." && len(p) > len(top); p = filepath.Dir(p) {
 					e := b.find(filepath.ToSlash(p))
 					if o != "." {
 						e.Origin = filepath.ToSlash(o)
@@ -421,7 +663,7 @@ func (b *wcBuilder) subrepo() error {
 		for _, sub := range b.l.Subrepos[dir] {
 			name := sub.Name
 			if name == "" {
-				name = filepath.Base(sub.Src)
+				name = SubrepoName(sub.Src)
 			}
 			dst, err := b.alias(filepath.ToSlash(filepath.Join(dir, name)))
 			if err != nil {
@@ -459,9 +701,10 @@ func (b *wcBuilder) alias(path string) (string, error) {
 }
 
 func (b *wcBuilder) parents(path string, linkable bool) {
+	top := b.wc.rootOf(path)
 	inWC := true
 	for i, r := range path {
-		if r != '/' {
+		if r != '/' || i <= len(top) {
 			continue
 		}
 		p := path[:i]
@@ -485,7 +728,7 @@ func (b *wcBuilder) parents(path string, linkable bool) {
 					e.Type = unlinkable
 					delete(b.State, p)
 				}
-			case b.wc.Exists(p):
+			case b.wc.Exists(p) && !b.fold(p):
 				e.Type = unlinkable
 			}
 		} else {
@@ -494,6 +737,48 @@ func (b *wcBuilder) parents(path string, linkable bool) {
 	}
 }
 
+func (b *wcBuilder) style(e *Entry) (string, error) {
+	style, err := b.layers[e.Layer].LinkStyleOf(e.Path)
+	switch {
+	case err != nil:
+		return "", fmt.Errorf("layer '%v': %w", e.Layer, err)
+	case style == "":
+		style = b.wc.repo.Config.LinkStyle
+		if err := checkLinkStyle(style); err != nil {
+			return "", err
+		}
+	}
+	if style == "relative" {
+		style = ""
+	}
+	return style, nil
+}
+
+// fold reports whether the directory can be replaced with a link, that is
+// it only contains links which are tracked in the state.
+func (b *wcBuilder) fold(p string) bool {
+	if v, ok := b.foldable[p]; ok {
+		return v
+	}
+	v := false
+	if !b.wc.IsLink(p) {
+		list, err := os.ReadDir(b.wc.PathFor(p))
+		v = err == nil && len(list) > 0
+		for _, de := range list {
+			q := p + "/" + de.Name()
+			if _, ok := b.State[q]; ok && b.wc.IsLink(q) {
+				continue
+			}
+			if !de.IsDir() || b.wc.IsLink(q) || !b.fold(q) {
+				v = false
+				break
+			}
+		}
+	}
+	b.foldable[p] = v
+	return v
+}
+
 func (b *wcBuilder) find(p string) *Entry {
 	for _, e := range b.WC[p] {
 		if e.Layer == b.layer {
@@ -1,7 +1,7 @@
 //
 // nazuna :: wc_test.go
 //
-//   Copyright (c) 2013-2022 Akinori Hattori <hattya@gmail.com>
+//   Copyright (c) 2013-2026 Akinori Hattori <hattya@gmail.com>
 //
 //   SPDX-License-Identifier: MIT
 //
@@ -9,10 +9,12 @@
 package nazuna_test
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -100,6 +102,261 @@ func TestWCPaths(t *testing.T) {
 	}
 }
 
+func TestWCRoots(t *testing.T) {
+	repo := init_(t)
+
+	wc, err := repo.WC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	etc := filepath.Join(filepath.Dir(repo.Root()), "etc")
+	if err := mkdir(etc); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NAZUNA_ETC", etc)
+	repo.Config.Roots = []string{"$NAZUNA_ETC", "$NAZUNA_UNDEFINED"}
+
+	// not under roots
+	if _, err := wc.Rel('/', etc); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := wc.Rel('/', filepath.Join(filepath.Dir(repo.Root()), "file")); err == nil {
+		t.Error("expected error")
+	}
+	rel, err := wc.Rel('/', filepath.Join(etc, "app", "..", "app", "conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := rel, filepath.ToSlash(filepath.Join(etc, "app", "conf")); g != e {
+		t.Errorf("WC.Rel(...) = %q, expected %q", g, e)
+	}
+	if g, e := wc.PathFor(rel), filepath.Join(etc, "app", "conf"); g != e {
+		t.Errorf("WC.PathFor(%q) = %q, expected %q", rel, g, e)
+	}
+	// link
+	src := repo.PathFor(nil, "conf")
+	if err := touch(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := wc.Link(src, rel); err != nil {
+		t.Fatal(err)
+	}
+	if err := testLink(wc, src, rel); err != nil {
+		t.Error(err)
+	}
+	if err := wc.Unlink(rel); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(etc, "app")); err == nil {
+		t.Error("expected to remove parent directories")
+	}
+	if _, err := os.Stat(etc); err != nil {
+		t.Error("expected to keep root")
+	}
+
+	// merge
+	a, err := repo.NewLayer("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mkdir(repo.PathFor(a, "vim")); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []string{"conf", filepath.Join("vim", "vimrc")} {
+		if err := touch(repo.PathFor(a, n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Add("."); err != nil {
+		t.Fatal(err)
+	}
+	b, err := repo.NewLayer("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.NewAlias("conf", "$NAZUNA_ETC/app/conf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.NewAlias("vim", "$NAZUNA_ETC/vim"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wc.MergeLayers(); err != nil {
+		t.Fatal(err)
+	}
+	e := []*nazuna.Entry{
+		{
+			Layer:  a.Path(),
+			Path:   filepath.ToSlash(filepath.Join(etc, "app", "conf")),
+			Origin: "conf",
+		},
+		{
+			Layer:  a.Path(),
+			Path:   filepath.ToSlash(filepath.Join(etc, "vim")),
+			Origin: "vim",
+			IsDir:  true,
+		},
+	}
+	if !reflect.DeepEqual(wc.State.WC, e) {
+		for _, e := range wc.State.WC {
+			t.Logf("%+v", e)
+		}
+		t.Error("unexpected result")
+	}
+}
+
+func TestWCDeploy(t *testing.T) {
+	repo := init_(t)
+
+	if err := repo.Snapshot(); err == nil {
+		t.Error("expected error")
+	}
+	l, err := repo.NewLayer("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{".vimrc", filepath.Join(".vim", "syntax", "go.vim")} {
+		if err := mkdir(filepath.Dir(repo.PathFor(l, p))); err != nil {
+			t.Fatal(err)
+		}
+		if err := touch(repo.PathFor(l, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := l.NewSubrepo("github.com/hattya/vim-nazuna", ".vim/bundle/vim-nazuna"); err != nil {
+		t.Fatal(err)
+	}
+	sub := repo.SubrepoFor("github.com/hattya/vim-nazuna")
+	for _, p := range []string{"README", filepath.Join("doc", "nazuna.txt")} {
+		if err := mkdir(filepath.Dir(filepath.Join(sub, p))); err != nil {
+			t.Fatal(err)
+		}
+		if err := touch(sub, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Add("."); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(filepath.Dir(repo.Root()), "image")
+	root := filepath.Join(dir, strings.TrimPrefix(repo.Root(), filepath.VolumeName(repo.Root())))
+	d, err := repo.Deploy(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Config.LinkStyle = "absolute"
+	if err := d.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{
+		filepath.Join(root, ".nzn", "r", "nazuna.json"),
+		filepath.Join(root, ".nzn", "r", "a", ".vimrc"),
+	} {
+		if _, err := os.Stat(p); err != nil {
+			t.Error(err)
+		}
+	}
+	wc, err := d.WC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wc.MergeLayers(); err != nil {
+		t.Fatal(err)
+	}
+	if g, e := wc.PathFor(".vimrc"), filepath.Join(root, ".vimrc"); g != e {
+		t.Errorf("WC.PathFor(%q) = %q, expected %q", ".vimrc", g, e)
+	}
+	src := d.PathFor(l, ".vimrc")
+	if err := wc.Link(src, ".vimrc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testLink(wc, src, ".vimrc"); err != nil {
+		t.Error(err)
+	}
+	if runtime.GOOS != "windows" {
+		// valid in the image
+		r, err := os.Readlink(filepath.Join(root, ".vimrc"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g, e := r, repo.PathFor(l, ".vimrc"); g != e {
+			t.Errorf("expected %q, got %q", e, g)
+		}
+	}
+	if err := wc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, ".nzn", "state.json")); err != nil {
+		t.Error(err)
+	}
+	// real working copy
+	if _, err := os.Lstat(filepath.Join(repo.Root(), ".vimrc")); err == nil {
+		t.Error("expected to not link")
+	}
+	if _, err := os.Stat(filepath.Join(repo.Root(), ".nzn", "state.json")); err == nil {
+		t.Error("expected to not write state.json")
+	}
+	// symlink in the copy
+	if runtime.GOOS != "windows" {
+		outside := filepath.Join(filepath.Dir(repo.Root()), "outside")
+		if err := touch(outside); err != nil {
+			t.Fatal(err)
+		}
+		vimrc := filepath.Join(root, ".nzn", "r", "a", ".vimrc")
+		if err := os.Remove(vimrc); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(outside, vimrc); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(repo.PathFor(l, ".vimrc"), []byte("set nocompatible\n"), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Snapshot(); err != nil {
+			t.Fatal(err)
+		}
+		if data, err := os.ReadFile(outside); err != nil {
+			t.Error(err)
+		} else if len(data) != 0 {
+			t.Errorf("expected to not write %v", outside)
+		}
+		if fi, err := os.Lstat(vimrc); err != nil {
+			t.Error(err)
+		} else if !fi.Mode().IsRegular() {
+			t.Errorf("expected regular file: %v", vimrc)
+		}
+	}
+	// removed after the last snapshot
+	if err := repo.Command("rm", "-q", "-r", "-f", filepath.Join("a", ".vim")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(sub, "doc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{
+		filepath.Join(root, ".nzn", "r", "a", ".vim"),
+		filepath.Join(root, ".nzn", "sub", "github.com", "hattya", "vim-nazuna", "doc"),
+	} {
+		if _, err := os.Lstat(p); err == nil {
+			t.Errorf("expected to remove %v", p)
+		}
+	}
+	for _, p := range []string{
+		filepath.Join(root, ".nzn", "r", "a", ".vimrc"),
+		filepath.Join(root, ".nzn", "sub", "github.com", "hattya", "vim-nazuna", "README"),
+	} {
+		if _, err := os.Stat(p); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 func TestWCLinks(t *testing.T) {
 	repo := init_(t)
 
@@ -289,11 +546,6 @@ func TestMergeLayers(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	ui := new(testUI)
-	git, err := nazuna.VCSFor(ui, filepath.Join(".nzn", "r"))
-	if err != nil {
-		t.Fatal(err)
-	}
 	alias := func(l *nazuna.Layer, src, dst string) {
 		t.Helper()
 		if err := l.NewAlias(src, dst); err != nil {
@@ -458,7 +710,7 @@ func TestMergeLayers(t *testing.T) {
 	alias(b2, filepath.Join("dir5", "repo1a"), "repo6a")
 	alias(b2, filepath.Join("dir5", "repo1b"), "repo6b")
 
-	if err := git.Add("."); err != nil {
+	if err := repo.Add("."); err != nil {
 		t.Fatal(err)
 	}
 
@@ -785,6 +1037,387 @@ func TestMergeLayers(t *testing.T) {
 	}
 }
 
+func TestMergeLayersFolding(t *testing.T) {
+	repo := init_(t)
+
+	wc, err := repo.WC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := repo.NewLayer("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []string{
+		".config/foo/cache",
+		".config/foo/sub/file",
+		".vim/vimrc",
+	} {
+		if err := mkdir(filepath.Dir(repo.PathFor(a, n))); err != nil {
+			t.Fatal(err)
+		}
+		if err := touch(repo.PathFor(a, n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Add("."); err != nil {
+		t.Fatal(err)
+	}
+
+	update := func() []string {
+		t.Helper()
+		ul, err := wc.MergeLayers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range ul {
+			if err := wc.Unlink(e.Path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var list []string
+		for _, e := range wc.State.WC {
+			if !wc.LinksTo(e.Path, repo.PathFor(a, e.Path)) {
+				if err := wc.Link(repo.PathFor(a, e.Path), e.Path); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if e.IsDir {
+				list = append(list, e.Path+"/")
+			} else {
+				list = append(list, e.Path)
+			}
+		}
+		return list
+	}
+	if g, e := update(), []string{".config/", ".vim/"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	// fold → no-fold
+	a.Folding = map[string]string{
+		".config/foo": "no-fold",
+	}
+	if g, e := update(), []string{".config/foo/cache", ".config/foo/sub/file", ".vim/"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	if wc.IsLink(".config") {
+		t.Error("expected directory")
+	}
+	a.Folding = map[string]string{
+		"*":           "no-fold",
+		".config/foo": "fold",
+	}
+	if g, e := update(), []string{".config/foo/", ".vim/vimrc"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	// no-fold → fold
+	a.Folding = nil
+	if g, e := update(), []string{".config/", ".vim/"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	// untracked file
+	a.Folding = map[string]string{
+		".vim": "no-fold",
+	}
+	if g, e := update(), []string{".config/", ".vim/vimrc"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	if err := touch(wc.PathFor(".vim/viminfo")); err != nil {
+		t.Fatal(err)
+	}
+	a.Folding = nil
+	if g, e := update(), []string{".config/", ".vim/vimrc"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+
+	// error
+	a.Folding = map[string]string{
+		".config": "_",
+	}
+	if _, err := wc.MergeLayers(); err == nil {
+		t.Error("expected error")
+	}
+	a.Folding = map[string]string{
+		"[": "fold",
+	}
+	if _, err := wc.MergeLayers(); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestMergeLayersLinkStyle(t *testing.T) {
+	repo := init_(t)
+
+	wc, err := repo.WC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := repo.NewLayer("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []string{".gitconfig", ".vimrc"} {
+		if err := touch(repo.PathFor(a, n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Add("."); err != nil {
+		t.Fatal(err)
+	}
+
+	merge := func() ([]string, []string) {
+		t.Helper()
+		ul, err := wc.MergeLayers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var styles, paths []string
+		for _, e := range wc.State.WC {
+			styles = append(styles, e.Style)
+		}
+		for _, e := range ul {
+			paths = append(paths, e.Path)
+		}
+		return styles, paths
+	}
+	styles, ul := merge()
+	if g, e := styles, []string{"", ""}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	if len(ul) != 0 {
+		t.Errorf("unexpected unlink: %q", ul)
+	}
+	// working copy
+	repo.Config.LinkStyle = "absolute"
+	styles, ul = merge()
+	if g, e := styles, []string{"absolute", "absolute"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	if g, e := ul, []string{".gitconfig", ".vimrc"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	// entry
+	a.LinkStyle = map[string]string{
+		".vimrc": "hard",
+	}
+	styles, ul = merge()
+	if g, e := styles, []string{"absolute", "hard"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	if g, e := ul, []string{".vimrc"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	a.LinkStyle[".gitconfig"] = "relative"
+	styles, _ = merge()
+	if g, e := styles, []string{"", "hard"}; !reflect.DeepEqual(g, e) {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	for _, e := range wc.State.WC {
+		if err := wc.Link(repo.PathFor(a, e.Path), e.Path); err != nil {
+			t.Fatal(err)
+		}
+		if err := testLink(wc, repo.PathFor(a, e.Path), e.Path); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// error
+	a.LinkStyle[".vimrc"] = "_"
+	if _, err := wc.MergeLayers(); err == nil {
+		t.Error("expected error")
+	}
+	a.LinkStyle = nil
+	repo.Config.LinkStyle = "_"
+	if _, err := wc.MergeLayers(); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestLinkHard(t *testing.T) {
+	repo := init_(t)
+	repo.Config.LinkStyle = "hard"
+
+	wc, err := repo.WC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := repo.NewLayer("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := repo.PathFor(a, ".vimrc")
+	if err := touch(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Add("."); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wc.MergeLayers(); err != nil {
+		t.Fatal(err)
+	}
+	if err := wc.Link(src, ".vimrc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testLink(wc, src, ".vimrc"); err != nil {
+		t.Error(err)
+	}
+	if err := wc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// replaced by the vcs
+	if err := os.Remove(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, []byte("set nocompatible\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	wc, err = repo.WC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wc.MergeLayers(); err != nil {
+		t.Fatal(err)
+	}
+	if !wc.IsLink(".vimrc") {
+		t.Errorf("wc.IsLink(%q) = false, expected true", ".vimrc")
+	}
+	if wc.LinksTo(".vimrc", src) {
+		t.Errorf("wc.LinksTo(%q) = true, expected false", ".vimrc")
+	}
+	if err := wc.Link(src, ".vimrc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testLink(wc, src, ".vimrc"); err != nil {
+		t.Error(err)
+	}
+	if data, err := os.ReadFile(wc.PathFor(".vimrc")); err != nil {
+		t.Error(err)
+	} else if g, e := string(data), "set nocompatible\n"; g != e {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	if err := wc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// not created by Link
+	if err := os.Link(src, wc.PathFor(".gvimrc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := testLink(wc, src, ".gvimrc"); err != nil {
+		t.Error(err)
+	}
+	if err := wc.Unlink(".gvimrc"); err != nil {
+		t.Error(err)
+	}
+	// replaced by the user
+	if err := os.Remove(wc.PathFor(".vimrc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(wc.PathFor(".vimrc"), []byte("MINE\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, []byte("set nocompatible\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	wc, err = repo.WC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wc.MergeLayers(); err != nil {
+		t.Fatal(err)
+	}
+	if wc.IsLink(".vimrc") {
+		t.Errorf("wc.IsLink(%q) = true, expected false", ".vimrc")
+	}
+	if err := wc.Link(src, ".vimrc"); err == nil {
+		t.Error("expected error")
+	}
+	if err := wc.Unlink(".vimrc"); err == nil {
+		t.Error("expected error")
+	}
+	if data, err := os.ReadFile(wc.PathFor(".vimrc")); err != nil {
+		t.Error(err)
+	} else if g, e := string(data), "MINE\n"; g != e {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+}
+
+func TestUntracked(t *testing.T) {
+	repo := init_(t)
+
+	wc, err := repo.WC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := repo.NewLayer("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mkdir(repo.PathFor(a, ".vim")); err != nil {
+		t.Fatal(err)
+	}
+	if err := touch(repo.PathFor(a, filepath.Join(".vim", "vimrc"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Add("."); err != nil {
+		t.Fatal(err)
+	}
+	if err := mkdir(repo.PathFor(a, filepath.Join(".vim", "swap"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := touch(repo.PathFor(a, filepath.Join(".vim", "swap", "vimrc.swp"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := touch(repo.PathFor(a, filepath.Join(".vim", "viminfo"))); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		e    *nazuna.Entry
+		list []string
+	}{
+		{
+			e: &nazuna.Entry{
+				Layer: "a",
+				Path:  ".vim",
+				IsDir: true,
+			},
+			list: []string{".vim/swap/vimrc.swp", ".vim/viminfo"},
+		},
+		{
+			e: &nazuna.Entry{
+				Layer:  "a",
+				Path:   "vimfiles",
+				Origin: ".vim",
+				IsDir:  true,
+			},
+			list: []string{"vimfiles/swap/vimrc.swp", "vimfiles/viminfo"},
+		},
+		{
+			e: &nazuna.Entry{
+				Layer: "a",
+				Path:  ".vim/vimrc",
+			},
+		},
+		{
+			e: &nazuna.Entry{
+				Layer: "b",
+				Path:  ".vim",
+				IsDir: true,
+			},
+		},
+	} {
+		switch g, err := wc.Untracked(tt.e); {
+		case err != nil:
+			t.Error(err)
+		case !reflect.DeepEqual(g, tt.list):
+			t.Errorf("expected %q, got %q", tt.list, g)
+		}
+	}
+}
+
 func TestMergeLayersError(t *testing.T) {
 	repo := init_(t)
 
@@ -808,11 +1441,6 @@ func TestMergeLayersError(t *testing.T) {
 		t.Error("expected error")
 	}
 
-	ui := new(testUI)
-	git, err := nazuna.VCSFor(ui, filepath.Join(".nzn", "r"))
-	if err != nil {
-		t.Fatal(err)
-	}
 	reset := func() {
 		a.Links = nil
 		a.Subrepos = nil
@@ -842,7 +1470,7 @@ func TestMergeLayersError(t *testing.T) {
 	if err := touch(repo.PathFor(a, "file2")); err != nil {
 		t.Fatal(err)
 	}
-	if err := git.Add("."); err != nil {
+	if err := repo.Add("."); err != nil {
 		t.Fatal(err)
 	}
 	if err := wc.SelectLayer(b1.Path()); err != nil {
@@ -918,6 +1546,79 @@ func TestMergeLayersError(t *testing.T) {
 	}
 }
 
+func TestRunHooks(t *testing.T) {
+	init_(t)
+
+	ui := new(testUI)
+	repo, err := nazuna.Open(ui, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := repo.NewLayer("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b1, err := repo.NewLayer("b/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := repo.LayerOf("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wc, err := repo.WC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wc.SelectLayer(b1.Path()); err != nil {
+		t.Fatal(err)
+	}
+
+	env := func(k string) string {
+		if runtime.GOOS == "windows" {
+			return "%" + k + "%"
+		}
+		return "$" + k
+	}
+	a.Hooks = map[string][]string{
+		"pre-update":  {"exit 1"},
+		"post-link":   {"echo " + env("NAZUNA_PATHS")},
+		"post-update": {"echo " + env("NAZUNA_HOOK") + " " + env("NAZUNA_LAYER"), "echo > hooked"},
+	}
+	b.Hooks = map[string][]string{
+		"post-update": {"echo b"},
+	}
+	b1.Hooks = map[string][]string{
+		"post-link":   {"echo " + env("NAZUNA_PATHS")},
+		"post-update": {"echo " + env("NAZUNA_LAYER")},
+	}
+
+	if err := wc.RunHooks("post-update", nil); err != nil {
+		t.Fatal(err)
+	}
+	if g, e := strings.ReplaceAll(ui.String(), "\r", ""), "b\nb/1\npost-update a\n"; g != e {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+	if _, err := os.Stat("hooked"); err != nil {
+		t.Error(err)
+	}
+	ui.Reset()
+	if err := wc.RunHooks("post-link", map[string][]string{"a": {"dir/file", "file"}}); err != nil {
+		t.Fatal(err)
+	}
+	if g, e := strings.ReplaceAll(ui.String(), "\r", ""), filepath.FromSlash("dir/file")+string(os.PathListSeparator)+"file\n"; g != e {
+		t.Errorf("expected %q, got %q", e, g)
+	}
+
+	var he *nazuna.HookError
+	switch err := wc.RunHooks("pre-update", nil); {
+	case !errors.As(err, &he):
+		t.Errorf("expected *HookError, got %#v", err)
+	case he.Layer != "a" || he.Event != "pre-update":
+		t.Error("unexpected error:", err)
+	}
+}
+
 func TestWCErrorf(t *testing.T) {
 	repo := init_(t)
 